@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+var (
+	analyzeFlags     = flag.NewFlagSet("analyze", flag.ExitOnError)
+	analyzeEstimator = analyzeFlags.String("estimator", "mean", "How to aggregate the recorded deltas into the estimate: mean, harmonic, median, ewma, welford, kalman or a percentile such as p90.")
+	analyzeMAD       = analyzeFlags.Float64("mad", 0, "Exclude deltas more than this many median absolute deviations from the median from the estimate (0 keeps all).")
+	analyzeUnit      = analyzeFlags.String("unit", "B", "The unit of buffer sizes: B, KB, MB, KiB, MiB, bit, Kbit, Mbit, Kibit or Mibit.")
+)
+
+// recorded is the part of a report written with -format json or -output
+// that analyze reads.
+type recorded struct {
+	Options struct {
+		Size uint64 `json:"size"`
+		URL  string `json:"url"`
+	} `json:"options"`
+	Result *struct {
+		Deltas []int64 `json:"deltas_ns"`
+	} `json:"result"`
+	Error string `json:"error"`
+}
+
+// analyze re-estimates the implied buffer size from the deltas recorded in
+// the JSON reports named by args and returns the process's exit code.
+func analyze(args []string) int {
+	analyzeFlags.Parse(args)
+	// The command line takes precedence over the environment.
+	sources := map[string]string{}
+	analyzeFlags.Visit(func(f *flag.Flag) { sources[f.Name] = "command line" })
+	if err := applyEnvironment(analyzeFlags, sources); err != nil {
+		fmt.Printf("error: %v\n", err)
+		return exitUsage
+	}
+	if analyzeFlags.NArg() == 0 {
+		fmt.Printf("error: no reports to analyze.\n")
+		return exitUsage
+	}
+	unit, err := kmh.ParseUnit(*analyzeUnit)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return exitUsage
+	}
+	if _, err := kmh.NewStatistic(*analyzeEstimator); err != nil {
+		fmt.Printf("error: %v\n", err)
+		return exitUsage
+	}
+
+	code := exitOK
+	for _, name := range analyzeFlags.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Printf("error: %v\n", err)
+			code = exitInternal
+			continue
+		}
+		report := recorded{}
+		if err := json.Unmarshal(data, &report); err != nil {
+			fmt.Printf("error: %v: %v\n", name, err)
+			code = exitInternal
+			continue
+		}
+		if report.Result == nil {
+			fmt.Printf("%v: the test of %v failed: %v\n", name, report.Options.URL, report.Error)
+			continue
+		}
+
+		// Each report is estimated with a statistic of its own.
+		statistic, _ := kmh.NewStatistic(*analyzeEstimator)
+		estimated := kmh.RejectOutliers(report.Result.Deltas, *analyzeMAD)
+		for _, delta := range estimated {
+			statistic.Add(time.Duration(delta))
+		}
+		estimate := statistic.Result()
+		fmt.Printf("%v: %v deltas (%v excluded), estimated delta %v, implied buffer size %v\n", name,
+			len(estimated), len(report.Result.Deltas)-len(estimated), estimate, unit.Format(estimate.Seconds()*float64(report.Options.Size)))
+	}
+	return code
+}
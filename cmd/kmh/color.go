@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ANSI escape sequences used to color text output.
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+)
+
+// labelWidth is the column at which the colon after each label of the text
+// output is aligned.
+const labelWidth = 42
+
+// useColor reports whether text output is colored given the -color setting:
+// always, never or auto, which colors only a terminal and honors NO_COLOR.
+func useColor(setting string) (bool, error) {
+	switch setting {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout), nil
+	}
+	return false, fmt.Errorf("unknown color setting: %v", setting)
+}
+
+// colorize colors the lines of text output: labels in cyan, warnings in
+// yellow, errors in red and the implied buffer size in bold green. If color
+// is false, text is returned unchanged.
+func colorize(text string, color bool) string {
+	if !color {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "warning:"):
+			lines[i] = colorYellow + line + colorReset
+		case strings.HasPrefix(line, "error:"):
+			lines[i] = colorRed + line + colorReset
+		case strings.HasPrefix(line, "KMH Implied Buffer Size:"):
+			lines[i] = colorBold + colorGreen + line + colorReset
+		case len(line) > labelWidth && line[labelWidth] == ':':
+			lines[i] = colorCyan + line[:labelWidth] + colorReset + line[labelWidth:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
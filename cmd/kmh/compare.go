@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// target is the outcome of the test of one URL.
+type target struct {
+	URL    string
+	Result kmh.Result
+	Err    error
+}
+
+// compare returns a table comparing the implied buffer size, in unit, the
+// goodput, the number of deltas and the baseline RTT of each target.
+func compare(targets []target, unit kmh.Unit) string {
+	table := strings.Builder{}
+	writer := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "Target\tImplied buffer size\tGoodput\tDeltas\tBaseline RTT")
+	for _, tested := range targets {
+		if tested.Err != nil {
+			fmt.Fprintf(writer, "%v\terror\t-\t-\t-\n", tested.URL)
+			continue
+		}
+		rtt := "-"
+		if tested.Result.Connection.RTT != 0 {
+			rtt = tested.Result.Connection.RTT.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%.2f Kbit/s\t%v\t%v\n", tested.URL, unit.Format(tested.Result.ImpliedBufferBytes),
+			tested.Result.Goodput*8/1000, tested.Result.Samples, rtt)
+	}
+	writer.Flush()
+	return table.String()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// commandFlags returns the flags of each subcommand that has any.
+func commandFlags() map[string]*flag.FlagSet {
+	return map[string]*flag.FlagSet{"client": flag.CommandLine, "serve": serveFlags, "analyze": analyzeFlags}
+}
+
+// commandSummaries describe the subcommands offered for completion.
+var commandSummaries = map[string]string{
+	"client":     "Measure the buffer in front of a periodic endpoint",
+	"serve":      "Serve a periodic endpoint",
+	"analyze":    "Re-estimate the buffer from saved JSON reports",
+	"completion": "Print a bash, zsh or fish completion script",
+}
+
+// shells are the shells for which completion scripts can be printed.
+var shells = []string{"bash", "zsh", "fish"}
+
+// completion prints the completion script for the shell named by args and
+// returns the process's exit code.
+func completion(args []string) int {
+	if len(args) != 1 {
+		fmt.Printf("error: usage: kmh completion bash|zsh|fish\n")
+		return exitUsage
+	}
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print(zshCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		fmt.Printf("error: unknown shell: %v\n", args[0])
+		return exitUsage
+	}
+	return exitOK
+}
+
+// commandNames returns the names of the subcommands in order.
+func commandNames() []string {
+	names := []string{}
+	for name := range commandSummaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flagNames returns the names of the flags in set, each preceded by a hyphen.
+func flagNames(set *flag.FlagSet) []string {
+	names := []string{}
+	set.VisitAll(func(f *flag.Flag) { names = append(names, "-"+f.Name) })
+	return names
+}
+
+// summary returns the first sentence of a flag's usage.
+func summary(usage string) string {
+	if end := strings.Index(usage, ". "); end >= 0 {
+		usage = usage[:end]
+	}
+	return strings.TrimSuffix(usage, ".")
+}
+
+// singleQuote quotes value for a POSIX shell or zsh.
+func singleQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// bashCompletion returns a bash script completing subcommands and flags.
+func bashCompletion() string {
+	script := strings.Builder{}
+	commands := strings.Join(commandNames(), " ")
+	fmt.Fprintf(&script, `_kmh() {
+	local cur=${COMP_WORDS[COMP_CWORD]} command=client
+	case ${COMP_WORDS[1]} in
+	%v) command=${COMP_WORDS[1]} ;;
+	esac
+	if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then
+		COMPREPLY=($(compgen -W %v -- "$cur"))
+		return
+	fi
+	case $command in
+`, strings.ReplaceAll(commands, " ", "|"), singleQuote(commands))
+	sets := commandFlags()
+	for _, name := range commandNames() {
+		words := strings.Join(shells, " ")
+		if set, ok := sets[name]; ok {
+			words = strings.Join(flagNames(set), " ")
+		}
+		fmt.Fprintf(&script, "\t%v) COMPREPLY=($(compgen -W %v -- \"$cur\")) ;;\n", name, singleQuote(words))
+	}
+	script.WriteString("\tesac\n}\ncomplete -o default -F _kmh kmh\n")
+	return script.String()
+}
+
+// zshCompletion returns a zsh script completing subcommands and flags with
+// their descriptions.
+func zshCompletion() string {
+	script := strings.Builder{}
+	script.WriteString("#compdef kmh\n\n_kmh() {\n\tlocal -a commands flags\n\tcommands=(\n")
+	for _, name := range commandNames() {
+		fmt.Fprintf(&script, "\t\t%v\n", singleQuote(name+":"+commandSummaries[name]))
+	}
+	fmt.Fprintf(&script, `	)
+	if (( CURRENT == 2 )) && [[ $PREFIX != -* ]]; then
+		_describe 'command' commands
+		return
+	fi
+	local command=client
+	case $words[2] in
+	%v) command=$words[2] ;;
+	esac
+	case $command in
+`, strings.Join(commandNames(), "|"))
+	sets := commandFlags()
+	for _, name := range commandNames() {
+		set, ok := sets[name]
+		if !ok {
+			fmt.Fprintf(&script, "\t%v) compadd %v; return ;;\n", name, strings.Join(shells, " "))
+			continue
+		}
+		fmt.Fprintf(&script, "\t%v) flags=(\n", name)
+		set.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(&script, "\t\t%v\n", singleQuote("-"+f.Name+":"+summary(f.Usage)))
+		})
+		script.WriteString("\t) ;;\n")
+	}
+	script.WriteString(`	esac
+	if [[ $PREFIX == -* ]]; then
+		_describe 'flag' flags
+	else
+		_files
+	fi
+}
+
+compdef _kmh kmh
+`)
+	return script.String()
+}
+
+// fishCompletion returns a fish script completing subcommands and flags with
+// their descriptions.
+func fishCompletion() string {
+	script := strings.Builder{}
+	names := strings.Join(commandNames(), " ")
+	for _, name := range commandNames() {
+		fmt.Fprintf(&script, "complete -c kmh -n %v -f -a %v -d %v\n",
+			singleQuote("not __fish_seen_subcommand_from "+names), name, fishQuote(commandSummaries[name]))
+	}
+	sets := commandFlags()
+	for _, name := range commandNames() {
+		condition := "__fish_seen_subcommand_from " + name
+		if name == "client" {
+			// The client is also run when no subcommand is given.
+			others := []string{}
+			for _, other := range commandNames() {
+				if other != name {
+					others = append(others, other)
+				}
+			}
+			condition = "not __fish_seen_subcommand_from " + strings.Join(others, " ")
+		}
+		set, ok := sets[name]
+		if !ok {
+			fmt.Fprintf(&script, "complete -c kmh -n %v -f -a %v\n", singleQuote(condition), singleQuote(strings.Join(shells, " ")))
+			continue
+		}
+		set.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(&script, "complete -c kmh -n %v -o %v -d %v\n", singleQuote(condition), f.Name, fishQuote(summary(f.Usage)))
+		})
+	}
+	return script.String()
+}
+
+// fishQuote quotes value for fish, in which a backslash escapes a quote or
+// another backslash within single quotes.
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
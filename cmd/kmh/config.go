@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// configAliases are the keys of a configuration file that name a flag
+// differently.
+var configAliases = map[string]string{
+	"url":     "URL",
+	"target":  "URL",
+	"targets": "URL",
+}
+
+// profilesKey introduces the named profiles of a configuration file.
+const profilesKey = "profiles"
+
+// setting is a value given to a flag by a configuration file, outside of any
+// profile or in the named profile.
+type setting struct {
+	profile string
+	name    string
+	value   string
+	line    int
+}
+
+// parseConfig parses a configuration file of flag settings written in the
+// subset of YAML and TOML that flags need, and fails on anything outside it:
+//
+//   - one "key: value" or "key = value" per line, with keys, which are flag
+//     names in which underscores may stand for hyphens, all at the same
+//     indentation;
+//   - values that are bare, or quoted with double quotes, which may hold
+//     escapes, or single quotes, and which end on the line they begin;
+//   - lists given as "[a, b]" on one line, or as "- item" lines below a key
+//     with no value;
+//   - comments introduced by # outside of quotes, and "---" lines.
+//
+// Settings for a named profile follow a "[profiles.name]" table in TOML or
+// are indented below "name:" within a "profiles:" map in YAML. No other
+// tables or nested maps, inline tables, flow maps, multi-line strings, block
+// scalars, anchors, aliases or tags are supported.
+func parseConfig(name string) ([]setting, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	settings := []setting{}
+	listKey, profile := "", ""
+	// In a YAML profiles map, profileIndent is the indentation of the
+	// profiles' names; it is -1 until the first name is read. keyIndent is
+	// the indentation of the keys of the current profile, or -1 until its
+	// first key is read.
+	inProfiles, profileIndent, keyIndent := false, -1, 0
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		raw := stripComment(scanner.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" || line == "---" {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+		fail := func(format string, args ...any) ([]setting, error) {
+			return nil, fmt.Errorf("%v:%v: %v", name, number, fmt.Sprintf(format, args...))
+		}
+
+		if strings.HasPrefix(line, "[") && !strings.ContainsAny(line, "=:") {
+			table := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+			named, ok := strings.CutPrefix(table, profilesKey+".")
+			if !ok || named == "" || strings.ContainsAny(named, "[].") {
+				return fail("unsupported table: %v", line)
+			}
+			named, err := unquote(named)
+			if err != nil {
+				return fail("%v", err)
+			}
+			listKey, profile, keyIndent = "", named, -1
+			continue
+		}
+		if inProfiles && indent == 0 {
+			inProfiles, profile, keyIndent = false, "", 0
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok {
+			if listKey == "" {
+				return fail("list item without a key")
+			}
+			value, err := parseValue(strings.TrimSpace(item))
+			if err != nil {
+				return fail("%v", err)
+			}
+			settings = append(settings, setting{profile: profile, name: listKey, value: value, line: number})
+			continue
+		}
+		separator := strings.IndexAny(line, ":=")
+		if separator <= 0 {
+			return fail("expected key: value or key = value")
+		}
+		key := strings.TrimSpace(line[:separator])
+		value := strings.TrimSpace(line[separator+1:])
+		if key == profilesKey && value == "" && indent == 0 {
+			listKey, inProfiles, profileIndent = "", true, -1
+			continue
+		}
+		if inProfiles && (profileIndent == -1 || indent <= profileIndent) {
+			if value != "" {
+				return fail("expected the name of a profile")
+			}
+			named, err := unquote(key)
+			if err != nil {
+				return fail("%v", err)
+			}
+			listKey, profile, profileIndent, keyIndent = "", named, indent, -1
+			continue
+		}
+		if keyIndent == -1 {
+			keyIndent = indent
+		}
+		if indent != keyIndent {
+			return fail("nested maps are supported only for profiles")
+		}
+		if alias, ok := configAliases[strings.ToLower(key)]; ok {
+			key = alias
+		} else {
+			key = strings.ReplaceAll(key, "_", "-")
+		}
+		if flag.Lookup(key) == nil {
+			return fail("unknown setting: %v", key)
+		}
+
+		listKey = ""
+		switch {
+		case value == "":
+			listKey = key
+		case strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return fail("lists must end on the line they begin")
+			}
+			items, err := splitList(value[1 : len(value)-1])
+			if err != nil {
+				return fail("%v", err)
+			}
+			for _, item := range items {
+				parsed, err := parseValue(item)
+				if err != nil {
+					return fail("%v", err)
+				}
+				settings = append(settings, setting{profile: profile, name: key, value: parsed, line: number})
+			}
+		default:
+			parsed, err := parseValue(value)
+			if err != nil {
+				return fail("%v", err)
+			}
+			settings = append(settings, setting{profile: profile, name: key, value: parsed, line: number})
+		}
+	}
+	return settings, scanner.Err()
+}
+
+// parseValue returns the value of a setting or list item, rejecting the
+// syntax that parseConfig does not support.
+func parseValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''"):
+		return "", errors.New("multi-line strings are not supported")
+	case value == "|" || value == ">" || strings.HasPrefix(value, "|-") || strings.HasPrefix(value, ">-") ||
+		strings.HasPrefix(value, "|+") || strings.HasPrefix(value, ">+"):
+		return "", errors.New("block scalars are not supported")
+	case strings.HasPrefix(value, "{"):
+		return "", errors.New("inline tables and flow maps are not supported")
+	case strings.HasPrefix(value, "&") || strings.HasPrefix(value, "*"):
+		return "", errors.New("anchors and aliases are not supported")
+	case strings.HasPrefix(value, "!"):
+		return "", errors.New("tags are not supported")
+	case strings.HasPrefix(value, "["):
+		return "", errors.New("nested lists are not supported")
+	}
+	return unquote(value)
+}
+
+// splitList splits the items of a one-line list at the commas outside of
+// quotes, dropping empty items.
+func splitList(list string) ([]string, error) {
+	items := []string{}
+	quote, start := rune(0), 0
+	add := func(end int) {
+		if item := strings.TrimSpace(list[start:end]); item != "" {
+			items = append(items, item)
+		}
+	}
+	for i, r := range list {
+		switch {
+		case quote != 0 && r == quote && (quote == '\'' || !escaped(list[:i])):
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == ',':
+			add(i)
+			start = i + 1
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	add(len(list))
+	return items, nil
+}
+
+// escaped reports whether the character following prefix, within double
+// quotes, is escaped by an odd number of backslashes.
+func escaped(prefix string) bool {
+	return (len(prefix)-len(strings.TrimRight(prefix, "\\")))%2 == 1
+}
+
+// stripComment removes a comment, introduced by # outside of quotes, from line.
+func stripComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote && (quote == '\'' || !escaped(line[:i])):
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote removes the quotes around a quoted value, failing if they are not
+// closed.
+func unquote(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") || strings.Contains(value[1:len(value)-1], "'") {
+			return "", fmt.Errorf("unterminated or invalid quote: %v", value)
+		}
+		return value[1 : len(value)-1], nil
+	case strings.HasPrefix(value, `"`):
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("unterminated or invalid quote: %v", value)
+		}
+		return unquoted, nil
+	}
+	return value, nil
+}
+
+// environmentPrefix begins the name of the environment variable that sets
+// each flag: KMH_ followed by the flag's name in upper case, with underscores
+// for hyphens, such as KMH_URL or KMH_MIN_SAMPLES. The flags of the serve and
+// analyze subcommands, some of which share the client's names, also carry the
+// subcommand's, such as KMH_SERVE_ADDRESS.
+const environmentPrefix = "KMH_"
+
+// environmentName returns the name of the environment variable that sets the
+// flag name of set.
+func environmentName(set *flag.FlagSet, name string) string {
+	if set != flag.CommandLine {
+		name = set.Name() + "_" + name
+	}
+	return environmentPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnvironment gives the flags of set without a source in sources the
+// values of their environment variables and records the variables as their
+// sources.
+func applyEnvironment(set *flag.FlagSet, sources map[string]string) error {
+	var err error
+	set.VisitAll(func(f *flag.Flag) {
+		name := environmentName(set, f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok || sources[f.Name] != "" || err != nil {
+			return
+		}
+		if setErr := set.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value for %v: %w", name, setErr)
+			return
+		}
+		sources[f.Name] = name
+	})
+	return err
+}
+
+// applyConfig gives the flags without a source in sources the values from
+// the configuration file name: those of the named profile and, for the flags
+// the profile does not set, those outside of any profile. It records the file
+// as their source.
+func applyConfig(name string, profile string, sources map[string]string) error {
+	settings, err := parseConfig(name)
+	if err != nil {
+		return err
+	}
+	profiled := map[string]bool{}
+	found := profile == ""
+	for _, configured := range settings {
+		if profile != "" && configured.profile == profile {
+			profiled[configured.name], found = true, true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%v: unknown profile: %v", name, profile)
+	}
+	applied := map[string]string{}
+	for _, configured := range settings {
+		if sources[configured.name] != "" || configured.profile != profile && (configured.profile != "" || profiled[configured.name]) {
+			continue
+		}
+		if err := flag.Set(configured.name, configured.value); err != nil {
+			return fmt.Errorf("%v:%v: invalid value for %v: %w", name, configured.line, configured.name, err)
+		}
+		applied[configured.name] = name
+		if configured.profile != "" {
+			applied[configured.name] = fmt.Sprintf("%v, profile %v", name, configured.profile)
+		}
+	}
+	for flagName, source := range applied {
+		sources[flagName] = source
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes contents to a configuration file in a temporary
+// directory and returns its name.
+func writeConfig(t *testing.T, contents string) string {
+	name := filepath.Join(t.TempDir(), "kmh.conf")
+	if err := os.WriteFile(name, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     []setting
+	}{
+		{"YAML", "size: 1024\nbuffer: 256 # bytes\n",
+			[]setting{{"", "size", "1024", 1}, {"", "buffer", "256", 2}}},
+		{"TOML", "size = 1024\nread_timeout = \"5s\"\n",
+			[]setting{{"", "size", "1024", 1}, {"", "read-timeout", "5s", 2}}},
+		{"blank lines and document markers", "---\n\n# comment\nsize: 1\n",
+			[]setting{{"", "size", "1", 4}}},
+		{"double quotes", `pin: "a#b\"c"`,
+			[]setting{{"", "pin", `a#b"c`, 1}}},
+		{"single quotes", `pin: 'a # b'`,
+			[]setting{{"", "pin", "a # b", 1}}},
+		{"alias", "url: example.com/periodic\n",
+			[]setting{{"", "URL", "example.com/periodic", 1}}},
+		{"inline list", `resolve = ["a:1:192.0.2.1", 'b:2:192.0.2.2', ]`,
+			[]setting{{"", "resolve", "a:1:192.0.2.1", 1}, {"", "resolve", "b:2:192.0.2.2", 1}}},
+		{"block list", "resolve:\n  - a:1:192.0.2.1\n  - \"b:2:192.0.2.2\"\n",
+			[]setting{{"", "resolve", "a:1:192.0.2.1", 2}, {"", "resolve", "b:2:192.0.2.2", 3}}},
+		{"TOML profile", "size = 1\n[profiles.slow]\nsize = 2\n",
+			[]setting{{"", "size", "1", 1}, {"slow", "size", "2", 3}}},
+		{"YAML profiles", "size: 1\nprofiles:\n  slow:\n    size: 2\n  fast:\n    size: 3\nbuffer: 4\n",
+			[]setting{{"", "size", "1", 1}, {"slow", "size", "2", 4}, {"fast", "size", "3", 6}, {"", "buffer", "4", 7}}},
+	}
+	for _, test := range tests {
+		got, err := parseConfig(writeConfig(t, test.contents))
+		if err != nil {
+			t.Errorf("%v: parseConfig() = %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: parseConfig() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseConfigRejected(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"block scalar", "pin: |\n  a\n", "block scalars are not supported"},
+		{"multi-line string", `pin = """a"""`, "multi-line strings are not supported"},
+		{"inline table", "pin: {a: 1}", "inline tables and flow maps are not supported"},
+		{"anchor", "size: &size 1", "anchors and aliases are not supported"},
+		{"tag", "size: !!int 1", "tags are not supported"},
+		{"nested list", "resolve: [[a]]", "nested lists are not supported"},
+		{"list over lines", "resolve: [a,\n  b]", "lists must end on the line they begin"},
+		{"unterminated quote", `pin: "a`, "unterminated or invalid quote"},
+		{"unterminated quote in list", `resolve: ["a]`, "unterminated quote"},
+		{"other table", "[server]\nsize = 1", "unsupported table"},
+		{"item without key", "- a", "list item without a key"},
+		{"no separator", "size 1", "expected key: value or key = value"},
+		{"unknown setting", "nosuch: 1", "unknown setting: nosuch"},
+		{"nested map", "size:\n  buffer: 1", "nested maps are supported only for profiles"},
+		{"profile with a value", "profiles:\n  slow: 1", "expected the name of a profile"},
+	}
+	for _, test := range tests {
+		_, err := parseConfig(writeConfig(t, test.contents))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%v: parseConfig() = %v, want an error containing %q", test.name, err, test.want)
+		}
+	}
+}
+
+// restoreFlags restores the values of the named flags once the test ends.
+func restoreFlags(t *testing.T, names ...string) {
+	for _, name := range names {
+		name, value := name, flag.Lookup(name).Value.String()
+		t.Cleanup(func() { flag.Set(name, value) })
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	restoreFlags(t, "size", "buffer", "min-samples", "read-timeout")
+	name := writeConfig(t, strings.Join([]string{
+		"size: 100", "buffer: 100", "min-samples: 100", "read-timeout: 100s",
+		"profiles:", "  slow:", "    buffer: 200", "    min-samples: 200", "    read-timeout: 200s",
+	}, "\n"))
+
+	// The command line sets the size and the environment the size and the
+	// minimum number of samples.
+	flag.Set("size", "300")
+	sources := map[string]string{"size": "command line"}
+	t.Setenv("KMH_SIZE", "400")
+	t.Setenv("KMH_MIN_SAMPLES", "400")
+	if err := applyEnvironment(flag.CommandLine, sources); err != nil {
+		t.Fatalf("applyEnvironment() = %v", err)
+	}
+	if err := applyConfig(name, "slow", sources); err != nil {
+		t.Fatalf("applyConfig() = %v", err)
+	}
+
+	tests := []struct {
+		flag   string
+		value  string
+		source string
+	}{
+		{"size", "300", "command line"},
+		{"min-samples", "400", "KMH_MIN_SAMPLES"},
+		{"buffer", "200", name + ", profile slow"},
+		{"read-timeout", "3m20s", name + ", profile slow"},
+	}
+	for _, test := range tests {
+		if value := flag.Lookup(test.flag).Value.String(); value != test.value || sources[test.flag] != test.source {
+			t.Errorf("-%v = %v from %q, want %v from %q", test.flag, value, sources[test.flag], test.value, test.source)
+		}
+	}
+}
+
+func TestPrecedenceWithoutProfile(t *testing.T) {
+	restoreFlags(t, "buffer", "read-timeout")
+	name := writeConfig(t, "buffer = 100\n[profiles.slow]\nbuffer = 200\nread_timeout = \"200s\"\n")
+	sources := map[string]string{}
+	if err := applyConfig(name, "", sources); err != nil {
+		t.Fatalf("applyConfig() = %v", err)
+	}
+	if value := flag.Lookup("buffer").Value.String(); value != "100" || sources["buffer"] != name {
+		t.Errorf("-buffer = %v from %q, want 100 from %q", value, sources["buffer"], name)
+	}
+	if source, ok := sources["read-timeout"]; ok {
+		t.Errorf("-read-timeout set from %q, want the profile to be ignored", source)
+	}
+	if err := applyConfig(name, "fast", map[string]string{}); err == nil {
+		t.Errorf("applyConfig() with an unknown profile = nil, want an error")
+	}
+}
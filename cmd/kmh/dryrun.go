@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// redacted stands in for secrets printed by -dry-run.
+const redacted = "REDACTED"
+
+// secretFlags are the flags whose values -dry-run does not print.
+var secretFlags = map[string]bool{"token": true, "otlp-headers": true}
+
+// effectiveSettings lists the value of every flag and, for those not left
+// at their defaults, where the value came from.
+func effectiveSettings(sources map[string]string) string {
+	settings := strings.Builder{}
+	settings.WriteString("Effective settings:\n")
+	flag.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if secretFlags[f.Name] && value != "" {
+			value = redacted
+		} else {
+			value = redactUserinfo(value)
+		}
+		source := "default"
+		if sources[f.Name] != "" {
+			source = sources[f.Name]
+		}
+		fmt.Fprintf(&settings, "  -%v=%v (%v)\n", f.Name, value, source)
+	})
+	return settings.String()
+}
+
+// redactUserinfo redacts the user information, which may carry a password or
+// a token, of the URLs in value, which may list several separated by commas,
+// such as those of -proxy, -push-gateway or -URL. A URL without a scheme, such
+// as a target, is read as one.
+func redactUserinfo(value string) string {
+	values := strings.Split(value, ",")
+	for i, v := range values {
+		parse := v
+		if !strings.Contains(v, "://") {
+			parse = "//" + v
+		}
+		parsed, err := url.Parse(parse)
+		if err != nil || parsed.User == nil {
+			continue
+		}
+		parsed.User = url.User(redacted)
+		values[i] = strings.TrimPrefix(parsed.String(), "//")
+	}
+	return strings.Join(values, ",")
+}
+
+// dryRequest describes the request that would be issued to test url with
+// client, speaking HTTP version, with any token or user information redacted.
+func dryRequest(url string, client *http.Client, version string) (string, error) {
+	token := *token
+	if token != "" {
+		token = redacted
+	}
+	url = redactUserinfo(url)
+	request, err := kmh.NewSession(kmh.Config{
+		URL: url, Size: *size, Client: client, Token: token, Transport: *transport,
+	}).Describe()
+	if err != nil {
+		return "", err
+	}
+	described := strings.Builder{}
+	fmt.Fprintf(&described, "Request for %v:\n  %v\n", url, request)
+	if *transport != kmh.TransportTCP && *transport != kmh.TransportUDP {
+		fmt.Fprintf(&described, "  HTTP version: %v\n", version)
+		if token != "" {
+			fmt.Fprintf(&described, "  Authorization: Bearer %v\n", token)
+		}
+	}
+	return described.String(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// The exit codes of a measurement, on which scripts may branch.
+const (
+	// exitOK: the measurement succeeded.
+	exitOK = 0
+	// exitInternal: the result could not be reported.
+	exitInternal = 1
+	// exitUsage: the command line is invalid, as the flag package's own
+	// exit code for unparseable flags is.
+	exitUsage = 2
+	// exitConnection: the measurement could not be started because the
+	// server could not be reached or refused the test.
+	exitConnection = 3
+	// exitTLS: the TLS handshake with the server failed.
+	exitTLS = 4
+	// exitShortSample: too few deltas were recorded.
+	exitShortSample = 5
+	// exitThreshold: the implied buffer size exceeded -max-buffer.
+	exitThreshold = 6
+)
+
+func init() {
+	usage := flag.Usage
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), `Usage: kmh [client] [flags]
+       kmh serve [flags]
+       kmh analyze [flags] report.json...
+       kmh completion bash|zsh|fish
+
+`)
+		usage()
+		fmt.Fprintf(flag.CommandLine.Output(), `Each flag may also be set by an environment variable named %vFLAG, with the
+flag's name in upper case and underscores for hyphens (such as %vMIN_SAMPLES),
+or in the file given with -config. The command line takes precedence over the
+environment, which takes precedence over the file. The flags of kmh serve and
+kmh analyze are set by variables that also name the subcommand (such as
+%vSERVE_ADDRESS or %vANALYZE_UNIT).
+
+`, environmentPrefix, environmentPrefix, environmentPrefix, environmentPrefix)
+		fmt.Fprintf(flag.CommandLine.Output(), `Exit status:
+  %v  the measurement succeeded
+  %v  the result could not be reported
+  %v  the command line is invalid
+  %v  the server could not be reached or refused the test
+  %v  the TLS handshake failed
+  %v  too few deltas were recorded
+  %v  the implied buffer size exceeded -max-buffer
+`, exitOK, exitInternal, exitUsage, exitConnection, exitTLS, exitShortSample, exitThreshold)
+	}
+}
+
+// exitCode returns the exit code of a measurement that produced result or
+// could not be started because of err. A maxBuffer above zero is the largest
+// acceptable implied buffer size.
+func exitCode(result kmh.Result, err error, maxBuffer float64) int {
+	if err != nil {
+		var record tls.RecordHeaderError
+		var alert tls.AlertError
+		var verification *tls.CertificateVerificationError
+		var authority x509.UnknownAuthorityError
+		var hostname x509.HostnameError
+		var invalid x509.CertificateInvalidError
+		if errors.As(err, &record) || errors.As(err, &alert) || errors.As(err, &verification) ||
+			errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid) ||
+			errors.Is(err, kmh.ErrPinMismatch) {
+			return exitTLS
+		}
+		return exitConnection
+	}
+	for _, err := range result.Errors {
+		if errors.Is(err, kmh.ErrShortSample) {
+			return exitShortSample
+		}
+	}
+	if maxBuffer > 0 && result.ImpliedBufferBytes > maxBuffer {
+		return exitThreshold
+	}
+	return exitOK
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// influxEscaper escapes tag keys and values in InfluxDB line protocol.
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// parseTags parses tags given as comma-separated key=value pairs.
+func parseTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid tag: %v", pair)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// influxLine formats result as a point of the kmh measurement in InfluxDB
+// line protocol, tagged with the target and transport of the test and tags.
+func influxLine(settings options, tags map[string]string, result kmh.Result, at time.Time) string {
+	all := map[string]string{"target": settings.URL, "transport": settings.Transport}
+	for key, value := range tags {
+		all[key] = value
+	}
+	keys := make([]string, 0, len(all))
+	for key := range all {
+		keys = append(keys, key)
+	}
+	// Sorted tags are the most efficient for InfluxDB to index.
+	sort.Strings(keys)
+
+	line := strings.Builder{}
+	line.WriteString("kmh")
+	for _, key := range keys {
+		fmt.Fprintf(&line, ",%v=%v", influxEscaper.Replace(key), influxEscaper.Replace(all[key]))
+	}
+	fmt.Fprintf(&line, " implied_buffer_bytes=%v,estimate_ns=%vi,samples=%vi,stddev_ns=%vi,jitter_ns=%vi,duration_ns=%vi,errors=%vi",
+		result.ImpliedBufferBytes, result.Estimate.Nanoseconds(), result.Samples, result.StdDev.Nanoseconds(),
+		result.Jitter.Nanoseconds(), result.Duration.Nanoseconds(), len(result.Errors))
+	if result.Grade != "" {
+		fmt.Fprintf(&line, ",grade=%q", result.Grade)
+	}
+	fmt.Fprintf(&line, " %v", at.UnixNano())
+	return line.String()
+}
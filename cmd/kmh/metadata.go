@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// metadata describes where, when and with what a test was run, so that an
+// archived result can be interpreted later.
+type metadata struct {
+	Timestamp time.Time `json:"timestamp"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Version   string    `json:"version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Kernel    string    `json:"kernel,omitempty"`
+	ServerIP  string    `json:"server_ip,omitempty"`
+}
+
+// newMetadata describes a test that finished at over connection.
+func newMetadata(at time.Time, connection kmh.Connection) metadata {
+	described := metadata{Timestamp: at.UTC(), Version: version(), OS: runtime.GOOS, Arch: runtime.GOARCH, Kernel: kernel()}
+	described.Hostname, _ = os.Hostname()
+	if host, _, err := net.SplitHostPort(connection.RemoteAddress); err == nil {
+		described.ServerIP = host
+	}
+	return described
+}
+
+// String formats the metadata like the lines of the text summary, which
+// gives the server's address itself.
+func (m metadata) String() string {
+	lines := strings.Builder{}
+	fmt.Fprintf(&lines, "Test finished at                          : %v\n", m.Timestamp.Format(time.RFC3339))
+	if m.Hostname != "" {
+		fmt.Fprintf(&lines, "Client host                               : %v\n", m.Hostname)
+	}
+	fmt.Fprintf(&lines, "kmh version                               : %v\n", m.Version)
+	if m.Kernel != "" {
+		fmt.Fprintf(&lines, "Client OS                                 : %v/%v (kernel %v)\n", m.OS, m.Arch, m.Kernel)
+	} else {
+		fmt.Fprintf(&lines, "Client OS                                 : %v/%v\n", m.OS, m.Arch)
+	}
+	return lines.String()
+}
+
+// version returns the module version of the binary or, for a binary built
+// from a checkout, the commit from which it was built.
+func version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	revision, modified := "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// kernel returns the release of the running kernel, where it can be read.
+func kernel() string {
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(release))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+	"github.com/hawkinsw/measure-buffer/v2/pkg/otlp"
+)
+
+// deltaBounds are the upper bounds, in seconds, of the buckets of the
+// kmh.delta histogram.
+var deltaBounds = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10}
+
+// newExporter returns an exporter to endpoint that presents headers, given
+// as in OTEL_EXPORTER_OTLP_HEADERS, and names the service as in
+// OTEL_SERVICE_NAME or, if that is not set, kmh.
+func newExporter(endpoint string, headers string) (otlp.Exporter, error) {
+	parsed, err := otlp.ParseHeaders(headers)
+	if err != nil {
+		return otlp.Exporter{}, err
+	}
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "kmh"
+	}
+	return otlp.Exporter{Endpoint: endpoint, Headers: parsed, Service: service}, nil
+}
+
+// export exports a span for the test that ran from start to end, with an
+// event for each delta, and metrics for its deltas and estimate.
+func export(exporter otlp.Exporter, settings options, start time.Time, end time.Time, result kmh.Result, err error) error {
+	ctx, canceler := context.WithTimeout(context.Background(), pushTimeout)
+	defer canceler()
+
+	span := otlp.Span{
+		Name: "kmh.test", Start: start, End: end, Err: err,
+		Attributes: map[string]any{
+			"kmh.target":    settings.URL,
+			"kmh.transport": settings.Transport,
+			"kmh.size":      int64(settings.Size),
+			"kmh.estimator": settings.Estimator,
+		},
+	}
+	if err == nil {
+		span.Attributes["kmh.samples"] = result.Samples
+		span.Attributes["kmh.estimate_ns"] = result.Estimate.Nanoseconds()
+		span.Attributes["kmh.implied_buffer_bytes"] = result.ImpliedBufferBytes
+		for _, event := range result.Events {
+			span.Events = append(span.Events, otlp.Event{
+				Name: "delta", At: event.At,
+				Attributes: map[string]any{"kmh.delta_ns": event.Gap.Nanoseconds()},
+			})
+		}
+	}
+	if err := exporter.ExportSpan(ctx, span); err != nil {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+
+	deltas := make([]float64, len(result.Deltas))
+	for i, delta := range result.Deltas {
+		deltas[i] = time.Duration(delta).Seconds()
+	}
+	return exporter.ExportMetrics(ctx, []otlp.Gauge{
+		{Name: "kmh.estimate", Description: "The estimated delta.", Unit: "s", At: end, Value: result.Estimate.Seconds()},
+		{Name: "kmh.implied_buffer_bytes", Description: "The implied buffer size.", Unit: "By", At: end, Value: result.ImpliedBufferBytes},
+		{Name: "kmh.samples", Description: "Deltas recorded.", Unit: "{delta}", At: end, Value: float64(result.Samples)},
+	}, []otlp.Histogram{
+		{Name: "kmh.delta", Description: "The recorded deltas.", Unit: "s", Start: start, At: end, Bounds: deltaBounds, Values: deltas},
+	})
+}
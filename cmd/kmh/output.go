@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// newReport returns the JSON document describing a test with settings, run
+// as described, that produced result or failed with err.
+func newReport(settings options, described metadata, result kmh.Result, err error) report {
+	document := report{Options: settings, Metadata: described}
+	if err != nil {
+		document.Error = err.Error()
+	} else {
+		document.Result = &result
+	}
+	return document
+}
+
+// outputName expands the placeholders in pattern: {timestamp}, the time at
+// which the test finished, to the millisecond so that the tests of one run do
+// not overwrite each other's files, and {target}, the URL tested with the
+// characters that cannot appear in file names replaced.
+func outputName(pattern string, settings options, at time.Time) string {
+	target := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, settings.URL)
+	return strings.NewReplacer(
+		"{timestamp}", at.UTC().Format("20060102T150405.000Z"),
+		"{target}", target,
+	).Replace(pattern)
+}
+
+// writeOutput writes the test to the file named by pattern, as CSV if the
+// name ends in .csv and as JSON otherwise, and returns the file's name.
+func writeOutput(pattern string, settings options, described metadata, result kmh.Result, err error) (string, error) {
+	name := outputName(pattern, settings, described.Timestamp)
+	var data []byte
+	if strings.EqualFold(filepath.Ext(name), ".csv") {
+		data = csvRecords(settings, described, result, err)
+	} else {
+		encoded, err := json.MarshalIndent(newReport(settings, described, result, err), "", "  ")
+		if err != nil {
+			return "", err
+		}
+		data = append(encoded, '\n')
+	}
+	return name, writeAtomic(name, data)
+}
+
+// csvRecords returns a header and a row describing the test.
+func csvRecords(settings options, described metadata, result kmh.Result, err error) []byte {
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	buffer := bytes.Buffer{}
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{
+		"time", "target", "transport", "size", "samples", "estimate_ns", "implied_buffer_bytes",
+		"stddev_ns", "jitter_ns", "duration_ns", "grade", "hostname", "version", "os", "kernel", "server_ip", "error",
+	})
+	writer.Write([]string{
+		described.Timestamp.Format(time.RFC3339), settings.URL, settings.Transport, strconv.FormatUint(settings.Size, 10),
+		strconv.Itoa(result.Samples), strconv.FormatInt(result.Estimate.Nanoseconds(), 10),
+		strconv.FormatFloat(result.ImpliedBufferBytes, 'f', -1, 64), strconv.FormatInt(result.StdDev.Nanoseconds(), 10),
+		strconv.FormatInt(result.Jitter.Nanoseconds(), 10), strconv.FormatInt(result.Duration.Nanoseconds(), 10),
+		result.Grade, described.Hostname, described.Version, described.OS + "/" + described.Arch, described.Kernel,
+		described.ServerIP, message,
+	})
+	writer.Flush()
+	return buffer.Bytes()
+}
+
+// writeChart writes a chart of events to the file name, as an SVG or a PNG
+// image according to its extension.
+func writeChart(name string, events []kmh.DeltaEvent, buckets int) error {
+	draw := kmh.Chart
+	switch {
+	case strings.EqualFold(filepath.Ext(name), ".svg"):
+	case strings.EqualFold(filepath.Ext(name), ".png"):
+		draw = kmh.ChartPNG
+	default:
+		return fmt.Errorf("charts can only be written as SVG or PNG: %v", name)
+	}
+	chart := bytes.Buffer{}
+	if err := draw(&chart, events, buckets); err != nil {
+		return err
+	}
+	return writeAtomic(name, chart.Bytes())
+}
+
+// writeAtomic writes data to a temporary file beside name and renames it to
+// name, so that readers never see a partially written file.
+func writeAtomic(name string, data []byte) error {
+	temporary, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	// CreateTemp makes files only their owner may read.
+	if err := temporary.Chmod(0o644); err != nil {
+		temporary.Close()
+		return err
+	}
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), name); err != nil {
+		return fmt.Errorf("could not write %v: %w", name, err)
+	}
+	return nil
+}
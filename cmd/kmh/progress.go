@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// progressInterval is how often the progress bar is redrawn.
+const progressInterval = 250 * time.Millisecond
+
+// progressWidth is the number of cells in the progress bar.
+const progressWidth = 30
+
+// isTerminal reports whether f is a terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progress returns the function that redraws, in place, a bar of the time
+// elapsed out of timeout alongside the bytes received so far in unit. A test
+// extended past timeout by -min-samples shows a full bar.
+func progress(timeout time.Duration, unit kmh.Unit) func(kmh.Interim) {
+	return func(interim kmh.Interim) {
+		done := progressWidth
+		remaining := time.Duration(0)
+		if interim.Elapsed < timeout {
+			done = int(interim.Elapsed * progressWidth / timeout)
+			remaining = timeout - interim.Elapsed
+		}
+		fmt.Printf("\r[%v%v] %v elapsed, %v left, %v received ",
+			strings.Repeat("=", done), strings.Repeat(" ", progressWidth-done),
+			interim.Elapsed.Round(time.Second/10), remaining.Round(time.Second/10), unit.Format(float64(interim.Bytes)))
+	}
+}
+
+// clearProgress erases the line on which the progress bar was drawn.
+func clearProgress() {
+	fmt.Print("\r\033[K")
+}
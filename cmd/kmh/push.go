@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+	"github.com/hawkinsw/measure-buffer/v2/pkg/pushgateway"
+)
+
+// pushTimeout bounds how long pushing a result may take.
+const pushTimeout = 10 * time.Second
+
+// push pushes the metrics of result to the Pushgateway at gateway, labeled
+// with the target and transport of the test and this host's name.
+func push(gateway string, job string, settings options, result kmh.Result) error {
+	instance, _ := os.Hostname()
+	labels := []pushgateway.Label{
+		{Name: "instance", Value: instance},
+		{Name: "target", Value: settings.URL},
+		{Name: "transport", Value: settings.Transport},
+	}
+
+	gauges := []pushgateway.Gauge{
+		{Name: "kmh_implied_buffer_bytes", Help: "The implied buffer size.", Value: result.ImpliedBufferBytes},
+		{Name: "kmh_estimated_delta_seconds", Help: "The estimated delta.", Value: result.Estimate.Seconds()},
+		{Name: "kmh_samples", Help: "Deltas recorded.", Value: float64(result.Samples)},
+		{Name: "kmh_goodput_bits_per_second", Help: "Goodput over the test.", Value: result.Goodput * 8},
+		{Name: "kmh_last_run_timestamp_seconds", Help: "When the test finished, in seconds since the epoch.", Value: float64(time.Now().Unix())},
+	}
+
+	ctx, canceler := context.WithTimeout(context.Background(), pushTimeout)
+	defer canceler()
+	return pushgateway.Push(ctx, http.DefaultClient, gateway, job, labels, gauges)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/server"
+)
+
+var (
+	serveFlags       = flag.NewFlagSet("serve", flag.ExitOnError)
+	serveAddress     = serveFlags.String("address", ":443", "The address on which to listen.")
+	serveUnix        = serveFlags.String("unix", "", "The path of a Unix domain socket on which to listen instead of -address.")
+	serveCert        = serveFlags.String("cert", "", "The file holding the server's TLS certificate (generated if omitted).")
+	serveKey         = serveFlags.String("key", "", "The file holding the server's TLS key (generated if omitted).")
+	serveHostnames   = serveFlags.String("hostnames", "", "Comma-separated names for a generated certificate (default: localhost and this host's name).")
+	serveUDPAddress  = serveFlags.String("udp-address", "", "The address on which to serve datagram tests (default: disabled).")
+	serveTCPAddress  = serveFlags.String("tcp-address", "", "The address on which to serve raw TCP tests (default: disabled).")
+	serveHTTPVersion = serveFlags.String("http-version", "2", "The newest HTTP version offered to clients: 1.1 or 2.")
+	serveSize        = serveFlags.Uint64("size", 512, "The amount of data periodically sent when the client does not specify one.")
+	serveInterval    = serveFlags.Duration("interval", 2*time.Second, "The time between chunks of data when the client does not specify one.")
+	servePacing      = serveFlags.String("pacing", "fixed", "How chunks are spaced when the client does not specify: fixed, jittered or bursty.")
+	serveJitter      = serveFlags.Float64("jitter", 0.25, "The largest displacement of a jittered chunk, as a fraction of the interval.")
+	serveBurstChunks = serveFlags.Int("burst-chunks", 4, "The number of chunks sent together when pacing is bursty.")
+	serveMaxSize     = serveFlags.Uint64("max-size", 1<<20, "The largest amount of data a client may ask to be periodically sent.")
+	serveMinInterval = serveFlags.Duration("min-interval", 10*time.Millisecond, "The shortest interval between chunks a client may ask for.")
+	serveMaxInterval = serveFlags.Duration("max-interval", time.Minute, "The longest interval between chunks a client may ask for.")
+	serveMaxDuration = serveFlags.Duration("max-duration", 5*time.Minute, "The longest a test may last (0 for unlimited).")
+	serveMaxTests    = serveFlags.Int("max-concurrent", 64, "The largest number of tests that may run at once (0 for unlimited).")
+	servePerIPRate   = serveFlags.Float64("per-ip-rate", 30, "How many tests each client address may start per minute (0 for unlimited).")
+	servePerIPBurst  = serveFlags.Int("per-ip-burst", 5, "How many tests each client address may start in a burst.")
+	serveMetrics     = serveFlags.Bool("metrics", true, "Expose Prometheus metrics at /metrics.")
+	serveDrain       = serveFlags.Duration("drain-timeout", 30*time.Second, "How long tests in progress may continue after a request to shut down.")
+	serveAccessLog   = serveFlags.Bool("access-log", true, "Log one record per finished test.")
+	serveLogFormat   = serveFlags.String("log-format", "text", "How to format the log written to standard output: text or json.")
+	serveTokens      = serveFlags.String("tokens", "", "Comma-separated bearer tokens, one of which clients must present (default: no authorization).")
+)
+
+// serve runs the periodic endpoint described by args until it is asked to
+// shut down and returns the process's exit code.
+func serve(args []string) int {
+	serveFlags.Parse(args)
+	// The command line takes precedence over the environment.
+	sources := map[string]string{}
+	serveFlags.Visit(func(f *flag.Flag) { sources[f.Name] = "command line" })
+	if err := applyEnvironment(serveFlags, sources); err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+
+	logger, err := newLogger(os.Stdout, *serveLogFormat, slog.LevelInfo)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+		return 1
+	}
+	if *serveSize == 0 {
+		logger.Error("-size must be at least 1")
+		return 1
+	}
+	if (*serveCert == "") != (*serveKey == "") {
+		logger.Error("-cert and -key must be given together")
+		return 1
+	}
+	pacing, err := server.ParsePacing(*servePacing)
+	if err != nil {
+		logger.Error("invalid pacing", "error", err)
+		return 1
+	}
+	var hostnames []string
+	if *serveHostnames != "" {
+		hostnames = strings.Split(*serveHostnames, ",")
+	}
+	var tokens []string
+	if *serveTokens != "" {
+		tokens = strings.Split(*serveTokens, ",")
+	}
+
+	var accessLog *slog.Logger
+	if *serveAccessLog {
+		accessLog = logger
+	}
+
+	periodic := server.New(server.Config{
+		Address: *serveAddress, UnixSocket: *serveUnix, CertFile: *serveCert, KeyFile: *serveKey, Hostnames: hostnames,
+		HTTPVersion: *serveHTTPVersion,
+		Size:        *serveSize, Interval: *serveInterval,
+		Pacing: pacing, Jitter: *serveJitter, BurstChunks: *serveBurstChunks,
+		MaxSize: *serveMaxSize, MinInterval: *serveMinInterval, MaxInterval: *serveMaxInterval,
+		MaxDuration: *serveMaxDuration, MaxConcurrent: *serveMaxTests,
+		TestsPerMinute: *servePerIPRate, Burst: *servePerIPBurst, Metrics: *serveMetrics,
+		Tokens: tokens, AccessLog: accessLog,
+		UDPAddress: *serveUDPAddress, TCPAddress: *serveTCPAddress,
+	})
+
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	serveErr := make(chan error, 3)
+	go func() { serveErr <- periodic.ListenAndServe() }()
+	if *serveUnix != "" {
+		logger.Info("serving periodic endpoint", "address", *serveUnix)
+	} else {
+		logger.Info("serving periodic endpoint", "address", *serveAddress)
+	}
+	if *serveUDPAddress != "" {
+		go func() { serveErr <- periodic.ListenAndServeUDP() }()
+		logger.Info("serving datagram tests", "address", *serveUDPAddress)
+	}
+	if *serveTCPAddress != "" {
+		go func() { serveErr <- periodic.ListenAndServeTCP() }()
+		logger.Info("serving raw TCP tests", "address", *serveTCPAddress)
+	}
+
+	select {
+	case err := <-serveErr:
+		logger.Error("serving failed", "error", err)
+		return 1
+	case <-signals.Done():
+	}
+
+	logger.Info("draining tests in progress", "timeout", *serveDrain)
+	drainCtx, drainCanceler := context.WithTimeout(context.Background(), *serveDrain)
+	defer drainCanceler()
+	if err := periodic.Shutdown(drainCtx); err != nil {
+		logger.Warn("closed tests that did not finish", "error", err)
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/kmh"
+)
+
+// event is a line of the -stream output.
+type event struct {
+	Type      string         `json:"type"`
+	At        time.Time      `json:"at"`
+	Options   *options       `json:"options,omitempty"`
+	Delta     int64          `json:"delta_ns,omitempty"`
+	Estimate  int64          `json:"estimate_ns,omitempty"`
+	Elapsed   int64          `json:"elapsed_ns,omitempty"`
+	Samples   *int           `json:"samples,omitempty"`
+	Bytes     *uint64        `json:"bytes,omitempty"`
+	Metadata  *metadata      `json:"metadata,omitempty"`
+	Result    *kmh.Result    `json:"result,omitempty"`
+	URL       string         `json:"url,omitempty"`
+	Aggregate *kmh.Aggregate `json:"aggregate,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// stream prints events as newline-delimited JSON. Deltas and interim results
+// arrive from different goroutines, so events are written one at a time.
+type stream struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newStream(out io.Writer) *stream {
+	return &stream{encoder: json.NewEncoder(out)}
+}
+
+func (s *stream) write(e event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encoder.Encode(e)
+}
+
+func (s *stream) start(settings options) {
+	s.write(event{Type: "test_start", At: time.Now(), Options: &settings})
+}
+
+func (s *stream) delta(d time.Duration, ts time.Time) {
+	s.write(event{Type: "delta", At: ts, Delta: d.Nanoseconds()})
+}
+
+func (s *stream) estimate(estimate time.Duration, ts time.Time) {
+	s.write(event{Type: "interim_estimate", At: ts, Estimate: estimate.Nanoseconds()})
+}
+
+func (s *stream) interim(interim kmh.Interim) {
+	s.write(event{
+		Type: "interim_result", At: time.Now(), Elapsed: interim.Elapsed.Nanoseconds(),
+		Samples: &interim.Samples, Estimate: interim.Estimate.Nanoseconds(), Bytes: &interim.Bytes,
+	})
+}
+
+func (s *stream) end(described metadata, result kmh.Result, err error) {
+	end := event{Type: "test_end", At: described.Timestamp, Metadata: &described}
+	if err != nil {
+		end.Error = err.Error()
+	} else {
+		end.Result = &result
+	}
+	s.write(end)
+}
+
+func (s *stream) aggregate(url string, aggregate kmh.Aggregate) {
+	s.write(event{Type: "aggregate", At: time.Now(), URL: url, Aggregate: &aggregate})
+}
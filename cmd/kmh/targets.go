@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// readTargets reads the URLs listed one per line in the file name. Blank
+// lines and lines starting with # are skipped.
+func readTargets(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	urls := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = append(urls, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no targets in %v", name)
+	}
+	return urls, nil
+}
+
+// measureTargets tests each of urls in the given run, one after another or,
+// with -parallel, all at once, with insecure in place of -insecure, and prints
+// their results in the order of urls.
+// first is whether nothing has been printed before them and text whether
+// the results are printed as text. It stops early at a target whose settings
+// are invalid.
+func measureTargets(urls []string, insecure bool, run int, first bool, text bool) ([]target, []int) {
+	outcomes := make([]target, len(urls))
+	codes := make([]int, len(urls))
+	header := func(out io.Writer, i int) {
+		if (i > 0 || !first) && text {
+			fmt.Fprintln(out)
+		}
+		if *repeat > 1 && text && !*dryRun {
+			fmt.Fprintf(out, "Run %v of %v\n", run, *repeat)
+		}
+	}
+
+	if !*parallel {
+		for i, url := range urls {
+			header(os.Stdout, i)
+			outcomes[i], codes[i] = measure(url, insecure, os.Stdout)
+			if codes[i] == exitUsage {
+				return outcomes[:i+1], codes[:i+1]
+			}
+		}
+		return outcomes, codes
+	}
+
+	// The results of targets tested at once are held back until all have
+	// finished so that they are not interleaved.
+	outputs := make([]bytes.Buffer, len(urls))
+	wait := sync.WaitGroup{}
+	for i, url := range urls {
+		wait.Add(1)
+		go func(i int, url string) {
+			defer wait.Done()
+			header(&outputs[i], i)
+			outcomes[i], codes[i] = measure(url, insecure, &outputs[i])
+		}(i, url)
+	}
+	wait.Wait()
+	for i := range outputs {
+		os.Stdout.Write(outputs[i].Bytes())
+	}
+	return outcomes, codes
+}
@@ -1,5 +1,5 @@
 module github.com/hawkinsw/measure-buffer/v2
 
-go 1.20
+go 1.21
 
 require golang.org/x/exp v0.0.0-20230905200255-921286631fa9
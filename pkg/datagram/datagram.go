@@ -0,0 +1,89 @@
+// Package datagram defines the datagrams exchanged with the UDP periodic
+// sender.
+//
+// A client starts a test by sending a request datagram whose payload is a
+// URL-encoded query, with the same parameters as the HTTPS periodic endpoint
+// plus an optional token, padded to at least MinRequestSize bytes. The server
+// replies with a cookie datagram, and the client repeats its request with the
+// cookie as the cookie parameter, proving that it receives at the address
+// from which it asked. The server then sends data datagrams to that address.
+// Each begins with a Header, whose sequence numbers start at 1, and is padded
+// to the requested size. A datagram with sequence number 0 reports, after its
+// header, why the server refused the test.
+//
+// Until a client has echoed its cookie, the server sends it no datagram
+// larger than its request, so that the server cannot be used to amplify
+// traffic sent to a forged address.
+package datagram
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HeaderSize is the size of a Header on the wire.
+const HeaderSize = 16
+
+// MaxSize is the largest datagram the sender will send.
+const MaxSize = 65507
+
+// MinRequestSize is the smallest request the server answers.
+const MinRequestSize = 64
+
+// CookieSequence is the sequence number of a datagram that carries, after
+// its header, the cookie a client must echo to start its test.
+const CookieSequence = math.MaxUint64
+
+// PadRequest pads request, a URL-encoded query, with a pad parameter to at
+// least MinRequestSize bytes.
+func PadRequest(request string) string {
+	const pad = "pad="
+	if request != "" {
+		request += "&"
+	}
+	if missing := MinRequestSize - len(request) - len(pad); missing > 0 {
+		return request + pad + strings.Repeat("0", missing)
+	}
+	return request + pad
+}
+
+// CookieRequest returns request with cookie added as its cookie parameter.
+func CookieRequest(request string, cookie string) string {
+	query, _ := url.ParseQuery(request)
+	query.Set("cookie", cookie)
+	return query.Encode()
+}
+
+// Header begins every datagram sent by the server.
+type Header struct {
+	// Sequence numbers data datagrams from 1. Zero marks a refusal and
+	// CookieSequence a cookie.
+	Sequence uint64
+	// Sent is when the server sent the datagram.
+	Sent time.Time
+}
+
+// ErrShort is returned when a datagram is too small to hold a Header.
+var ErrShort = errors.New("datagram is shorter than its header")
+
+// Put writes h to the beginning of b, which must be at least HeaderSize
+// bytes long.
+func (h Header) Put(b []byte) {
+	binary.BigEndian.PutUint64(b[0:8], h.Sequence)
+	binary.BigEndian.PutUint64(b[8:16], uint64(h.Sent.UnixNano()))
+}
+
+// Parse reads the Header at the beginning of b.
+func Parse(b []byte) (Header, error) {
+	if len(b) < HeaderSize {
+		return Header{}, ErrShort
+	}
+	return Header{
+		Sequence: binary.BigEndian.Uint64(b[0:8]),
+		Sent:     time.Unix(0, int64(binary.BigEndian.Uint64(b[8:16]))),
+	}, nil
+}
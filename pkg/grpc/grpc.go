@@ -0,0 +1,212 @@
+// Package grpc implements the small part of the gRPC wire protocol needed to
+// carry a periodic test: the Periodic service described in periodic.proto,
+// whose messages are encoded here by hand.
+//
+// A call is an HTTP/2 POST to StreamMethod with the ContentType. Each message
+// in either direction is preceded by a one-byte compression flag, always
+// zero here, and its length as four big-endian bytes. The status of the call
+// is sent in the Grpc-Status and Grpc-Message trailers or, when the call
+// fails before any message is sent, headers.
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StreamMethod is the path of the Periodic service's Stream method.
+const StreamMethod = "/kmh.v1.Periodic/Stream"
+
+// ContentType is the content type of gRPC calls.
+const ContentType = "application/grpc"
+
+// MaxMessageSize is the largest message that ReadMessage accepts.
+const MaxMessageSize = 16 << 20
+
+// Status codes used by the Periodic service.
+const (
+	StatusOK                = 0
+	StatusInvalidArgument   = 3
+	StatusResourceExhausted = 8
+	StatusInternal          = 13
+	StatusUnavailable       = 14
+	StatusUnauthenticated   = 16
+)
+
+// ErrProtocol is returned when a peer violates the wire protocol.
+var ErrProtocol = errors.New("gRPC protocol error")
+
+// StreamRequest describes a test. Fields left at zero take the server's
+// defaults.
+type StreamRequest struct {
+	Size     uint64
+	Interval time.Duration
+	Duration time.Duration
+	Pacing   string
+}
+
+// Chunk is one chunk of a test.
+type Chunk struct {
+	// Sequence numbers chunks from 1.
+	Sequence uint64
+	Payload  []byte
+}
+
+// Wire types of protobuf fields.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendTag(b []byte, field int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarintField(b []byte, field int, value uint64) []byte {
+	if value == 0 {
+		return b
+	}
+	return binary.AppendUvarint(appendTag(b, field, wireVarint), value)
+}
+
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(appendTag(b, field, wireBytes), uint64(len(value)))
+	return append(b, value...)
+}
+
+// Marshal encodes r as a StreamRequest message.
+func (r StreamRequest) Marshal() []byte {
+	b := appendVarintField(nil, 1, r.Size)
+	b = appendVarintField(b, 2, uint64(r.Interval))
+	b = appendVarintField(b, 3, uint64(r.Duration))
+	return appendBytesField(b, 4, []byte(r.Pacing))
+}
+
+// Unmarshal decodes a StreamRequest message into r.
+func (r *StreamRequest) Unmarshal(b []byte) error {
+	*r = StreamRequest{}
+	return fields(b, func(field int, value uint64, bytes []byte) {
+		switch field {
+		case 1:
+			r.Size = value
+		case 2:
+			r.Interval = time.Duration(value)
+		case 3:
+			r.Duration = time.Duration(value)
+		case 4:
+			r.Pacing = string(bytes)
+		}
+	})
+}
+
+// Marshal encodes c as a Chunk message.
+func (c Chunk) Marshal() []byte {
+	b := make([]byte, 0, len(c.Payload)+2*binary.MaxVarintLen64)
+	b = appendVarintField(b, 1, c.Sequence)
+	return appendBytesField(b, 2, c.Payload)
+}
+
+// Unmarshal decodes a Chunk message into c. The payload refers to b.
+func (c *Chunk) Unmarshal(b []byte) error {
+	*c = Chunk{}
+	return fields(b, func(field int, value uint64, bytes []byte) {
+		switch field {
+		case 1:
+			c.Sequence = value
+		case 2:
+			c.Payload = bytes
+		}
+	})
+}
+
+// fields calls visit with each varint or length-delimited field of the
+// message in b, skipping fields of other wire types.
+func fields(b []byte, visit func(field int, value uint64, bytes []byte)) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return fmt.Errorf("%w: invalid field tag", ErrProtocol)
+		}
+		b = b[n:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		switch wire {
+		case wireVarint:
+			value, n := binary.Uvarint(b)
+			if n <= 0 {
+				return fmt.Errorf("%w: invalid varint", ErrProtocol)
+			}
+			b = b[n:]
+			visit(field, value, nil)
+		case wireBytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return fmt.Errorf("%w: invalid length", ErrProtocol)
+			}
+			b = b[n:]
+			visit(field, 0, b[:length])
+			b = b[length:]
+		case 1:
+			if len(b) < 8 {
+				return fmt.Errorf("%w: short fixed64", ErrProtocol)
+			}
+			b = b[8:]
+		case 5:
+			if len(b) < 4 {
+				return fmt.Errorf("%w: short fixed32", ErrProtocol)
+			}
+			b = b[4:]
+		default:
+			return fmt.Errorf("%w: unsupported wire type %v", ErrProtocol, wire)
+		}
+	}
+	return nil
+}
+
+// WriteMessage writes message to w, preceded by its length.
+func WriteMessage(w io.Writer, message []byte) error {
+	prefix := [5]byte{}
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+// ReadMessage reads the next message from r into buffer, growing it if
+// necessary, and returns the message. It returns io.EOF if r ends between
+// messages.
+func ReadMessage(r io.Reader, buffer []byte) ([]byte, error) {
+	prefix := [5]byte{}
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: truncated message", ErrProtocol)
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("%w: compressed messages are not supported", ErrProtocol)
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > MaxMessageSize {
+		return nil, fmt.Errorf("%w: message of %v bytes is too large", ErrProtocol, length)
+	}
+	if uint32(cap(buffer)) < length {
+		buffer = make([]byte, length)
+	}
+	buffer = buffer[:length]
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = fmt.Errorf("%w: truncated message", ErrProtocol)
+		}
+		return nil, err
+	}
+	return buffer, nil
+}
@@ -0,0 +1,123 @@
+package grpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request StreamRequest
+		// encoded is the message as protoc-generated code encodes it.
+		encoded []byte
+	}{
+		{"empty", StreamRequest{}, nil},
+		{"size", StreamRequest{Size: 512}, []byte{0x08, 0x80, 0x04}},
+		{"every field", StreamRequest{Size: 512, Interval: time.Second, Duration: time.Minute, Pacing: "poisson"}, []byte{
+			0x08, 0x80, 0x04,
+			0x10, 0x80, 0x94, 0xeb, 0xdc, 0x03,
+			0x18, 0x80, 0xb0, 0x9d, 0xc2, 0xdf, 0x01,
+			0x22, 0x07, 'p', 'o', 'i', 's', 's', 'o', 'n',
+		}},
+	}
+	for _, test := range tests {
+		if got := test.request.Marshal(); !bytes.Equal(got, test.encoded) {
+			t.Errorf("%v: Marshal() = %x, want %x", test.name, got, test.encoded)
+		}
+		decoded := StreamRequest{Size: 1}
+		if err := decoded.Unmarshal(test.encoded); err != nil || decoded != test.request {
+			t.Errorf("%v: Unmarshal() = %+v, %v, want %+v", test.name, decoded, err, test.request)
+		}
+	}
+}
+
+func TestChunk(t *testing.T) {
+	chunk := Chunk{Sequence: 1, Payload: []byte("abc")}
+	encoded := []byte{0x08, 0x01, 0x12, 0x03, 'a', 'b', 'c'}
+	if got := chunk.Marshal(); !bytes.Equal(got, encoded) {
+		t.Errorf("Marshal() = %x, want %x", got, encoded)
+	}
+
+	// Fields that are not in periodic.proto, of every wire type, are skipped.
+	unknown := append([]byte{
+		0x28, 0x07,
+		0x31, 1, 2, 3, 4, 5, 6, 7, 8,
+		0x3a, 0x01, 'x',
+		0x45, 1, 2, 3, 4,
+	}, encoded...)
+	for _, message := range [][]byte{encoded, unknown} {
+		decoded := Chunk{}
+		if err := decoded.Unmarshal(message); err != nil || !reflect.DeepEqual(decoded, chunk) {
+			t.Errorf("Unmarshal(%x) = %+v, %v, want %+v", message, decoded, err, chunk)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		message []byte
+	}{
+		{"truncated tag", []byte{0x80}},
+		{"truncated varint", []byte{0x08, 0x80}},
+		{"length past the end", []byte{0x12, 0x05, 'a'}},
+		{"short fixed64", []byte{0x09, 1, 2}},
+		{"short fixed32", []byte{0x0d, 1}},
+		{"group", []byte{0x0b}},
+	}
+	for _, test := range tests {
+		if err := (&Chunk{}).Unmarshal(test.message); !errors.Is(err, ErrProtocol) {
+			t.Errorf("%v: Unmarshal(%x) = %v, want %v", test.name, test.message, err, ErrProtocol)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	stream := bytes.Buffer{}
+	sent := [][]byte{
+		StreamRequest{Size: 512}.Marshal(),
+		Chunk{Sequence: 1, Payload: bytes.Repeat([]byte{0xaa}, 1000)}.Marshal(),
+		{},
+	}
+	for _, message := range sent {
+		if err := WriteMessage(&stream, message); err != nil {
+			t.Fatalf("WriteMessage() = %v", err)
+		}
+	}
+	if got, want := stream.Bytes()[:5], []byte{0, 0, 0, 0, 3}; !bytes.Equal(got, want) {
+		t.Errorf("length prefix = %x, want %x", got, want)
+	}
+
+	buffer := make([]byte, 0, 16)
+	for i, want := range sent {
+		got, err := ReadMessage(&stream, buffer)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("ReadMessage() of message %v = %x, %v, want %x", i, got, err, want)
+		}
+	}
+	if _, err := ReadMessage(&stream, buffer); err != io.EOF {
+		t.Errorf("ReadMessage() at the end = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream []byte
+	}{
+		{"truncated prefix", []byte{0, 0, 0}},
+		{"truncated message", []byte{0, 0, 0, 0, 4, 'a'}},
+		{"compressed", []byte{1, 0, 0, 0, 1, 'a'}},
+		{"too large", []byte{0, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, test := range tests {
+		if _, err := ReadMessage(bytes.NewReader(test.stream), nil); !errors.Is(err, ErrProtocol) {
+			t.Errorf("%v: ReadMessage() = %v, want %v", test.name, err, ErrProtocol)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package kmh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// Aggregate combines the estimates of repeated measurements of the same
+// path, so that the variability from run to run can be seen.
+type Aggregate struct {
+	// Runs is the number of measurements combined, and Failed the number of
+	// them that recorded no deltas and so were left out.
+	Runs   int
+	Failed int
+	// MeanEstimate, MedianEstimate, MinEstimate and MaxEstimate describe the
+	// runs' estimated deltas, and StdDevEstimate their sample standard
+	// deviation, which is zero for fewer than two runs.
+	MeanEstimate   time.Duration
+	MedianEstimate time.Duration
+	MinEstimate    time.Duration
+	MaxEstimate    time.Duration
+	StdDevEstimate time.Duration
+	// CV is the coefficient of variation of the runs' estimates.
+	CV float64
+	// ImpliedBufferBytes is the mean of the runs' implied buffer sizes, and
+	// ImpliedBufferRange their smallest and largest, in bytes.
+	ImpliedBufferBytes float64
+	ImpliedBufferRange [2]float64
+}
+
+type jsonAggregate struct {
+	Runs               int        `json:"runs"`
+	Failed             int        `json:"failed"`
+	MeanEstimate       int64      `json:"mean_estimate_ns"`
+	MedianEstimate     int64      `json:"median_estimate_ns"`
+	MinEstimate        int64      `json:"min_estimate_ns"`
+	MaxEstimate        int64      `json:"max_estimate_ns"`
+	StdDevEstimate     int64      `json:"stddev_estimate_ns"`
+	CV                 float64    `json:"cv"`
+	ImpliedBufferBytes float64    `json:"implied_buffer_bytes"`
+	ImpliedBufferRange [2]float64 `json:"implied_buffer_range"`
+}
+
+// AggregateResults combines results of repeated measurements. Results that
+// recorded no deltas are counted as failed and left out.
+func AggregateResults(results []Result) Aggregate {
+	aggregate := Aggregate{Runs: len(results)}
+	var estimates []time.Duration
+	var sizes []float64
+	for _, result := range results {
+		if result.Samples == 0 {
+			aggregate.Failed++
+			continue
+		}
+		estimates = append(estimates, result.Estimate)
+		sizes = append(sizes, result.ImpliedBufferBytes)
+	}
+	if len(estimates) == 0 {
+		return aggregate
+	}
+	aggregate.MeanEstimate = time.Duration(stats.Mean(estimates))
+	aggregate.MedianEstimate = time.Duration(stats.Median(estimates))
+	aggregate.MinEstimate = stats.Min(estimates)
+	aggregate.MaxEstimate = stats.Max(estimates)
+	if len(estimates) > 1 {
+		aggregate.StdDevEstimate = time.Duration(stats.StdDev(estimates))
+		aggregate.CV = stats.CV(estimates)
+	}
+	aggregate.ImpliedBufferBytes = stats.Mean(sizes)
+	aggregate.ImpliedBufferRange = [2]float64{stats.Min(sizes), stats.Max(sizes)}
+	return aggregate
+}
+
+// MarshalJSON encodes the aggregate with durations in nanoseconds.
+func (a Aggregate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonAggregate{
+		Runs:               a.Runs,
+		Failed:             a.Failed,
+		MeanEstimate:       a.MeanEstimate.Nanoseconds(),
+		MedianEstimate:     a.MedianEstimate.Nanoseconds(),
+		MinEstimate:        a.MinEstimate.Nanoseconds(),
+		MaxEstimate:        a.MaxEstimate.Nanoseconds(),
+		StdDevEstimate:     a.StdDevEstimate.Nanoseconds(),
+		CV:                 a.CV,
+		ImpliedBufferBytes: a.ImpliedBufferBytes,
+		ImpliedBufferRange: a.ImpliedBufferRange,
+	})
+}
+
+// String returns a human-readable summary of the aggregate with sizes in
+// bytes.
+func (a Aggregate) String() string {
+	return a.Format(Bytes)
+}
+
+// Format returns a human-readable summary of the aggregate with sizes in
+// unit.
+func (a Aggregate) Format(unit Unit) string {
+	summary := strings.Builder{}
+	fmt.Fprintf(&summary, "Runs                                      : %v (%v failed)\n", a.Runs, a.Failed)
+	if a.Runs == a.Failed {
+		fmt.Fprintf(&summary, "error: no run recorded any deltas.\n")
+		return strings.TrimSuffix(summary.String(), "\n")
+	}
+	fmt.Fprintf(&summary, "Mean estimated delta                      : %v\n", a.MeanEstimate)
+	fmt.Fprintf(&summary, "Median estimated delta                    : %v\n", a.MedianEstimate)
+	fmt.Fprintf(&summary, "Estimated delta range                     : %v - %v\n", a.MinEstimate, a.MaxEstimate)
+	if a.Runs-a.Failed > 1 {
+		fmt.Fprintf(&summary, "Run-to-run standard deviation             : %v (CV %.3f)\n", a.StdDevEstimate, a.CV)
+	}
+	fmt.Fprintf(&summary, "Implied buffer size range                 : %v - %v\n", unit.Format(a.ImpliedBufferRange[0]), unit.Format(a.ImpliedBufferRange[1]))
+	fmt.Fprintf(&summary, "KMH Implied Buffer Size (mean of runs): %v", unit.Format(a.ImpliedBufferBytes))
+	return summary.String()
+}
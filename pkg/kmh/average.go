@@ -0,0 +1,15 @@
+package kmh
+
+import "github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+
+// Number is the set of types that can be averaged.
+//
+// Deprecated: use stats.Number.
+type Number = stats.Number
+
+// Average returns the arithmetic mean of values.
+//
+// Deprecated: use stats.Mean.
+func Average[T Number](values []T) float64 {
+	return stats.Mean(values)
+}
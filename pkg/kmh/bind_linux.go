@@ -0,0 +1,26 @@
+package kmh
+
+import (
+	"net"
+	"syscall"
+)
+
+// bindToInterface configures dialer to send through the named interface,
+// whatever the routing table says. Binding to a device may require
+// CAP_NET_RAW.
+func bindToInterface(dialer *net.Dialer, name string) error {
+	if _, err := net.InterfaceByName(name); err != nil {
+		return err
+	}
+	dialer.Control = func(network, address string, conn syscall.RawConn) error {
+		var bindErr error
+		err := conn.Control(func(fd uintptr) {
+			bindErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, name)
+		})
+		if err != nil {
+			return err
+		}
+		return bindErr
+	}
+	return nil
+}
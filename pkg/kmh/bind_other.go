@@ -0,0 +1,29 @@
+//go:build !linux
+
+package kmh
+
+import (
+	"fmt"
+	"net"
+)
+
+// bindToInterface configures dialer to send from the first address of the
+// named interface. Without a way to bind to a device, the route taken still
+// depends on the routing table.
+func bindToInterface(dialer *net.Dialer, name string) error {
+	device, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	addresses, err := device.Addrs()
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if prefix, ok := address.(*net.IPNet); ok {
+			dialer.LocalAddr = &net.TCPAddr{IP: prefix.IP}
+			return nil
+		}
+	}
+	return fmt.Errorf("interface %v has no addresses", name)
+}
@@ -0,0 +1,84 @@
+package kmh
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KmhCalculator is an io.ReadCloser that wraps the body of a periodic stream
+// and records the gaps between the arrival of consecutive size-byte chunks.
+type KmhCalculator struct {
+	context context.Context
+	waiter  *sync.WaitGroup
+	start   time.Time
+	body    io.ReadCloser
+	closed  *atomic.Bool
+	done    *sync.Once
+	tracker tracker
+}
+
+// NewKmhCalculator creates a KmhCalculator that reads from body, which is
+// expected to carry a chunk of size bytes per period. Once the calculator is
+// closed or context is done, it reports ErrCancelled and, if waiter is not
+// nil, marks it as done once. If body ends first, the calculator reports
+// ErrSourceClosed. NewKmhCalculator panics if size is 0.
+func NewKmhCalculator(context context.Context, waiter *sync.WaitGroup, size uint64, body io.ReadCloser, options ...Option) KmhCalculator {
+	tracker := newTracker(size, options)
+	return KmhCalculator{
+		context: context, waiter: waiter, start: tracker.last, body: body, closed: &atomic.Bool{}, done: &sync.Once{},
+		tracker: tracker,
+	}
+}
+
+// OnDelta registers f to be called, from the reading goroutine, each time a
+// delta is recorded. ts is the time at which the chunk completing the delta
+// arrived.
+func (sr *KmhCalculator) OnDelta(f func(d time.Duration, ts time.Time)) {
+	sr.tracker.onDelta = f
+}
+
+// Deltas returns the recorded gaps, in nanoseconds.
+func (sr *KmhCalculator) Deltas() []int64 {
+	return sr.tracker.deltas()
+}
+
+// Events returns the recorded gaps along with when they occurred.
+func (sr *KmhCalculator) Events() []DeltaEvent {
+	return sr.tracker.snapshot().Events
+}
+
+// Snapshot returns the progress of the measurement so far. It is safe to
+// call while another goroutine is using the KmhCalculator.
+func (sr *KmhCalculator) Snapshot() Snapshot {
+	return sr.tracker.snapshot()
+}
+
+func (sr *KmhCalculator) Read(p []byte) (n int, err error) {
+	n, err = sr.body.Read(p)
+
+	sr.tracker.account(n)
+
+	if sr.closed.Load() || sr.context.Err() != nil {
+		if sr.waiter != nil {
+			// Reads may continue after the cancellation; the waiter is
+			// marked as done only for the first.
+			sr.done.Do(sr.waiter.Done)
+		}
+		err = ErrCancelled
+	} else if err == io.EOF {
+		err = ErrSourceClosed
+	}
+	return
+}
+
+// Close stops the measurement and closes the underlying body, unblocking any
+// Read in progress. It is safe to call more than once.
+func (sr *KmhCalculator) Close() error {
+	if sr.closed.Swap(true) {
+		return nil
+	}
+	return sr.body.Close()
+}
@@ -0,0 +1,51 @@
+package kmh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// endless is a body that never runs out of bytes, even once closed.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) { return len(p), nil }
+func (endless) Close() error               { return nil }
+
+func TestKmhCalculatorCancelled(t *testing.T) {
+	waiter := &sync.WaitGroup{}
+	waiter.Add(1)
+	calculator := NewKmhCalculator(context.Background(), waiter, 100, endless{})
+	calculator.Close()
+	// The waiter would panic if it were marked as done more than once.
+	for i := 0; i < 3; i++ {
+		if _, err := calculator.Read(make([]byte, 10)); !errors.Is(err, ErrCancelled) {
+			t.Errorf("Read() after Close = %v, want %v", err, ErrCancelled)
+		}
+	}
+	waiter.Wait()
+}
+
+func TestKmhCalculatorContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	waiter := &sync.WaitGroup{}
+	waiter.Add(1)
+	calculator := NewKmhCalculator(ctx, waiter, 100, endless{})
+	for i := 0; i < 3; i++ {
+		if _, err := calculator.Read(make([]byte, 10)); !errors.Is(err, ErrCancelled) {
+			t.Errorf("Read() after cancellation = %v, want %v", err, ErrCancelled)
+		}
+	}
+	waiter.Wait()
+}
+
+func TestKmhCalculatorSourceClosed(t *testing.T) {
+	calculator := NewKmhCalculator(context.Background(), nil, 100, io.NopCloser(strings.NewReader("")))
+	if _, err := calculator.Read(make([]byte, 10)); !errors.Is(err, ErrSourceClosed) {
+		t.Errorf("Read() at the end of the body = %v, want %v", err, ErrSourceClosed)
+	}
+}
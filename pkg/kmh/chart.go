@@ -0,0 +1,166 @@
+package kmh
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// The dimensions, in pixels, of a chart and of the margin around each of its
+// two plots.
+const (
+	chartWidth       = 800
+	chartHeight      = 600
+	chartMargin      = 60
+	chartPlotHeight  = (chartHeight - 3*chartMargin) / 2
+	chartPlotWidth   = chartWidth - 2*chartMargin
+	chartHistogramY  = 2*chartMargin + chartPlotHeight
+	chartTimeSeriesY = chartMargin
+)
+
+// canvas is what a chart is drawn on, in pixels from its top left corner.
+// Lines are black; the points, the polyline through them and the bars are
+// steel blue.
+type canvas interface {
+	line(x1, y1, x2, y2 float64)
+	polyline(points [][2]float64)
+	point(x, y float64)
+	bar(x, y, width, height float64)
+	// text writes s with its baseline at y, starting at x or, if end is
+	// true, ending at x.
+	text(x, y float64, s string, end bool, bold bool)
+}
+
+// Chart writes an SVG image to w plotting events: the deltas over time
+// above, and a histogram of them, with the given number of buckets, below.
+func Chart(w io.Writer, events []DeltaEvent, buckets int) error {
+	svg := &svgCanvas{}
+	fmt.Fprintf(&svg.Builder, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" font-family="sans-serif" font-size="12">`+"\n", chartWidth, chartHeight)
+	fmt.Fprintf(&svg.Builder, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	if err := drawChart(svg, events, buckets); err != nil {
+		return err
+	}
+	svg.WriteString("</svg>\n")
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+// ChartPNG writes the chart drawn by Chart to w as a PNG image.
+func ChartPNG(w io.Writer, events []DeltaEvent, buckets int) error {
+	raster := newRaster(chartWidth, chartHeight)
+	if err := drawChart(raster, events, buckets); err != nil {
+		return err
+	}
+	return png.Encode(w, raster.image)
+}
+
+// drawChart draws the plots of events, with a histogram of the given number
+// of buckets, on c.
+func drawChart(c canvas, events []DeltaEvent, buckets int) error {
+	if len(events) == 0 {
+		return errors.New("no deltas to chart")
+	}
+	if buckets < 1 {
+		return fmt.Errorf("a chart needs at least one bucket, not %v", buckets)
+	}
+	deltas := make([]int64, len(events))
+	for i, event := range events {
+		deltas[i] = event.Gap.Nanoseconds()
+	}
+	low, high := float64(stats.Min(deltas)), float64(stats.Max(deltas))
+	if high == low {
+		low, high = low*0.9, high*1.1+1
+	}
+	span := events[len(events)-1].At.Sub(events[0].At).Seconds()
+
+	// The deltas over time.
+	chartAxes(c, chartTimeSeriesY, "Delta over time",
+		"0s", time.Duration(span*float64(time.Second)).Round(time.Millisecond).String(),
+		time.Duration(low).Round(time.Microsecond).String(), time.Duration(high).Round(time.Microsecond).String())
+	points := make([][2]float64, len(events))
+	for i, event := range events {
+		x := float64(chartMargin)
+		if span > 0 {
+			x += event.At.Sub(events[0].At).Seconds() / span * chartPlotWidth
+		}
+		y := chartTimeSeriesY + chartPlotHeight - (float64(deltas[i])-low)/(high-low)*chartPlotHeight
+		points[i] = [2]float64{x, y}
+		c.point(x, y)
+	}
+	c.polyline(points)
+
+	// The histogram of the deltas.
+	counted := stats.LinearHistogram(deltas, buckets)
+	if len(counted) == 0 {
+		return errors.New("no deltas to count in the histogram")
+	}
+	fullest := 0
+	for _, bucket := range counted {
+		fullest = max(fullest, bucket.Count)
+	}
+	chartAxes(c, chartHistogramY, "Histogram of deltas",
+		time.Duration(counted[0].Low).Round(time.Microsecond).String(),
+		time.Duration(counted[len(counted)-1].High).Round(time.Microsecond).String(),
+		"0", fmt.Sprint(fullest))
+	width := float64(chartPlotWidth) / float64(len(counted))
+	for i, bucket := range counted {
+		height := float64(bucket.Count) / float64(fullest) * chartPlotHeight
+		c.bar(chartMargin+float64(i)*width, chartHistogramY+chartPlotHeight-height, width, height)
+	}
+	return nil
+}
+
+// chartAxes draws the title and axes of a plot whose top is at y, labelling
+// the ends of each axis.
+func chartAxes(c canvas, y float64, title string, xLow, xHigh, yLow, yHigh string) {
+	bottom := y + chartPlotHeight
+	c.text(chartMargin, y-10, title, false, true)
+	c.line(chartMargin, bottom, chartMargin+chartPlotWidth, bottom)
+	c.line(chartMargin, y, chartMargin, bottom)
+	c.text(chartMargin, bottom+16, xLow, false, false)
+	c.text(chartMargin+chartPlotWidth, bottom+16, xHigh, true, false)
+	c.text(chartMargin-4, bottom, yLow, true, false)
+	c.text(chartMargin-4, y+10, yHigh, true, false)
+}
+
+// svgCanvas draws a chart as the elements of an SVG image.
+type svgCanvas struct {
+	strings.Builder
+}
+
+func (s *svgCanvas) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(s, `<line x1="%v" y1="%v" x2="%v" y2="%v" stroke="black"/>`+"\n", x1, y1, x2, y2)
+}
+
+func (s *svgCanvas) polyline(points [][2]float64) {
+	formatted := make([]string, len(points))
+	for i, point := range points {
+		formatted[i] = fmt.Sprintf("%.1f,%.1f", point[0], point[1])
+	}
+	fmt.Fprintf(s, `<polyline points="%v" fill="none" stroke="steelblue"/>`+"\n", strings.Join(formatted, " "))
+}
+
+func (s *svgCanvas) point(x, y float64) {
+	fmt.Fprintf(s, `<circle cx="%.1f" cy="%.1f" r="2" fill="steelblue"/>`+"\n", x, y)
+}
+
+func (s *svgCanvas) bar(x, y, width, height float64) {
+	fmt.Fprintf(s, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="steelblue" stroke="white"/>`+"\n", x, y, width, height)
+}
+
+func (s *svgCanvas) text(x, y float64, text string, end bool, bold bool) {
+	attributes := ""
+	if end {
+		attributes += ` text-anchor="end"`
+	}
+	if bold {
+		attributes += ` font-weight="bold"`
+	}
+	fmt.Fprintf(s, `<text x="%v" y="%v"%v>%v</text>`+"\n", x, y, attributes, html.EscapeString(text))
+}
@@ -0,0 +1,80 @@
+package kmh
+
+import (
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// DeliveryClass summarizes the chunks that were delivered in one way.
+type DeliveryClass struct {
+	// Chunks is the number of chunks in the class.
+	Chunks int
+	// Bytes is the number of bytes in those chunks.
+	Bytes uint64
+	// MeanGap and MedianGap describe the gaps before those chunks.
+	MeanGap   time.Duration
+	MedianGap time.Duration
+}
+
+type jsonDeliveryClass struct {
+	Chunks    int    `json:"chunks"`
+	Bytes     uint64 `json:"bytes"`
+	MeanGap   int64  `json:"mean_gap_ns"`
+	MedianGap int64  `json:"median_gap_ns"`
+}
+
+func (c DeliveryClass) json() jsonDeliveryClass {
+	return jsonDeliveryClass{Chunks: c.Chunks, Bytes: c.Bytes, MeanGap: c.MeanGap.Nanoseconds(), MedianGap: c.MedianGap.Nanoseconds()}
+}
+
+// Classify divides the chunks of size bytes that arrived after gaps into
+// those that were paced, arriving after a gap longer than filter, as the
+// server sent them, and those that were drained, arriving close behind the
+// chunk before them, from a buffer that had held them.
+func Classify(gaps []time.Duration, filter time.Duration, size uint64) (DeliveryClass, DeliveryClass) {
+	var paced, drained []time.Duration
+	for _, gap := range gaps {
+		if gap > filter {
+			paced = append(paced, gap)
+		} else {
+			drained = append(drained, gap)
+		}
+	}
+	return deliveryClass(paced, size), deliveryClass(drained, size)
+}
+
+func deliveryClass(gaps []time.Duration, size uint64) DeliveryClass {
+	class := DeliveryClass{Chunks: len(gaps), Bytes: uint64(len(gaps)) * size}
+	if len(gaps) > 0 {
+		class.MeanGap = time.Duration(stats.Mean(gaps))
+		class.MedianGap = time.Duration(stats.Median(gaps))
+	}
+	return class
+}
+
+// MaxBurst returns the most bytes delivered in a single burst: a chunk
+// followed by the chunks of size bytes that arrived no more than filter
+// after the chunk before them.
+func MaxBurst(gaps []time.Duration, filter time.Duration, size uint64) uint64 {
+	longest, run := 0, 0
+	for _, gap := range gaps {
+		if gap > filter {
+			run = 0
+		}
+		run++
+		longest = max(longest, run)
+	}
+	return uint64(longest) * size
+}
+
+// QueueDelay returns the delay a buffer added to the chunks of size bytes
+// it released in a burst of burst bytes: the time the chunks behind the first
+// take to drain at goodput bytes per second. A burst of a single chunk was
+// not held, so adds none.
+func QueueDelay(burst uint64, size uint64, goodput float64) time.Duration {
+	if burst <= size || goodput <= 0 {
+		return 0
+	}
+	return time.Duration(float64(burst-size) / goodput * float64(time.Second))
+}
@@ -0,0 +1,53 @@
+package kmh
+
+import "testing"
+
+func TestPeriodicURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   string
+		fails  bool
+	}{
+		{"no scheme", "example.com/periodic", "https://example.com/periodic?size=512", false},
+		{"http", "http://example.com:8080/periodic", "http://example.com:8080/periodic?size=512", false},
+		{"size replaced", "https://example.com/periodic?size=1&interval=2s", "https://example.com/periodic?interval=2s&size=512", false},
+		{"websocket", "ws://example.com/websocket", "http://example.com/websocket?size=512", false},
+		{"secure websocket", "wss://example.com/websocket", "https://example.com/websocket?size=512", false},
+		{"fragment dropped", "https://example.com/periodic#top", "https://example.com/periodic?size=512", false},
+		{"unsupported scheme", "ftp://example.com/periodic", "", true},
+		{"no host", "https:///periodic", "", true},
+		{"invalid", "https://example.com:port/periodic", "", true},
+	}
+	for _, test := range tests {
+		got, err := PeriodicURL(test.target, 512)
+		if (err != nil) != test.fails || got != test.want {
+			t.Errorf("%v: PeriodicURL(%q, 512) = %q, %v, want %q (error %v)", test.name, test.target, got, err, test.want, test.fails)
+		}
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		key     string
+		address string
+		fails   bool
+	}{
+		{"IPv4", "example.com:443:192.0.2.1", "example.com:443", "192.0.2.1", false},
+		{"IPv6", "example.com:443:2001:db8::1", "example.com:443", "2001:db8::1", false},
+		{"bracketed IPv6", "example.com:443:[2001:db8::1]", "example.com:443", "2001:db8::1", false},
+		{"no address", "example.com:443", "", "", true},
+		{"no host", ":443:192.0.2.1", "", "", true},
+		{"no port", "example.com::192.0.2.1", "", "", true},
+		{"not an address", "example.com:443:example.net", "", "", true},
+	}
+	for _, test := range tests {
+		key, address, err := ParseResolve(test.entry)
+		if (err != nil) != test.fails || key != test.key || address != test.address {
+			t.Errorf("%v: ParseResolve(%q) = %q, %q, %v, want %q, %q (error %v)", test.name, test.entry,
+				key, address, err, test.key, test.address, test.fails)
+		}
+	}
+}
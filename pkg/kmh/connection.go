@@ -0,0 +1,148 @@
+package kmh
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+)
+
+// Connection describes how a stream reached the measurement.
+type Connection struct {
+	// Protocol is the negotiated application protocol, such as "HTTP/2.0".
+	Protocol string `json:"protocol,omitempty"`
+	// TLSVersion is the negotiated TLS version, such as "TLS 1.3", if the
+	// stream was encrypted.
+	TLSVersion string `json:"tls_version,omitempty"`
+	// Proxy is the URL, without its password, of the proxy through which
+	// the stream was carried, if any. A proxy adds buffers of its own.
+	Proxy string `json:"proxy,omitempty"`
+	// RemoteAddress is the address to which the stream's connection was
+	// made: that of the server or, if there was one, the proxy.
+	RemoteAddress string `json:"remote_address,omitempty"`
+	// Family is the family of RemoteAddress: "IPv4", "IPv6" or "unix".
+	Family string `json:"family,omitempty"`
+	// LocalAddress is the address from which the connection was made.
+	LocalAddress string `json:"local_address,omitempty"`
+	// MPTCP, when Multipath TCP was requested, reports whether it was
+	// negotiated. Subflow scheduling changes how chunks arrive.
+	MPTCP *bool `json:"mptcp,omitempty"`
+	// RTT is how long the TCP handshake took, a baseline round-trip time to
+	// RemoteAddress before the stream filled any buffer. It is zero when the
+	// handshake was not observed, as when a connection was reused.
+	RTT time.Duration `json:"rtt_ns,omitempty"`
+}
+
+// multipathConn is a connection dialed with Multipath TCP requested.
+type multipathConn struct {
+	*net.TCPConn
+}
+
+// setTLS records the negotiated TLS version, if state is not nil.
+func (c *Connection) setTLS(state *tls.ConnectionState) {
+	if state != nil {
+		c.TLSVersion = tls.VersionName(state.Version)
+	}
+}
+
+// setAddresses records the local and remote addresses of conn.
+func (c *Connection) setAddresses(conn net.Conn) {
+	if tls, ok := conn.(interface{ NetConn() net.Conn }); ok {
+		conn = tls.NetConn()
+	}
+	if multipath, ok := conn.(multipathConn); ok {
+		negotiated, _ := multipath.MultipathTCP()
+		c.MPTCP = &negotiated
+	}
+	if local := conn.LocalAddr(); local != nil {
+		c.LocalAddress = local.String()
+	}
+	address := conn.RemoteAddr()
+	if address == nil {
+		return
+	}
+	c.RemoteAddress = address.String()
+	var ip net.IP
+	switch address := address.(type) {
+	case *net.TCPAddr:
+		ip = address.IP
+	case *net.UDPAddr:
+		ip = address.IP
+	case *net.UnixAddr:
+		c.Family = "unix"
+		return
+	}
+	switch {
+	case ip.To4() != nil:
+		c.Family = "IPv4"
+	case ip != nil:
+		c.Family = "IPv6"
+	}
+}
+
+// traceAddresses returns request, traced so that the addresses of the
+// connection that carries it, and how long its handshake took, are recorded
+// in connection.
+func traceAddresses(request *http.Request, connection *Connection) *http.Request {
+	var start time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			start = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil && connection.RTT == 0 {
+				connection.RTT = time.Since(start)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			connection.setAddresses(info.Conn)
+		},
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+}
+
+// Described is implemented by streams that can describe their connection.
+// Sources may return such a stream from Open so that its description is
+// included in the Result.
+type Described interface {
+	Connection() Connection
+}
+
+// describedBody is a stream along with the description of its connection.
+type describedBody struct {
+	io.ReadCloser
+	connection Connection
+}
+
+func (b describedBody) Connection() Connection {
+	return b.connection
+}
+
+// proxyTransport is a transport whose connections are made through a proxy
+// that http.Transport does not know about, such as a SOCKS5 proxy reached by
+// its DialContext.
+type proxyTransport struct {
+	*http.Transport
+	proxy *url.URL
+}
+
+// proxyFor returns the URL, without its password, of the proxy through which
+// client sends request, or an empty string if it connects directly.
+func proxyFor(client *http.Client, request *http.Request) string {
+	var proxy *url.URL
+	switch transport := client.Transport.(type) {
+	case proxyTransport:
+		proxy = transport.proxy
+	case *http.Transport:
+		if transport.Proxy != nil {
+			proxy, _ = transport.Proxy(request)
+		}
+	}
+	if proxy == nil {
+		return ""
+	}
+	return proxy.Redacted()
+}
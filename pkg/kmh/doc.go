@@ -0,0 +1,9 @@
+// Package kmh measures the implied buffer size of a network path.
+//
+// A periodic server sends a fixed-size chunk of data at a regular interval. A
+// KmhCalculator wraps the stream carrying that data and records the time
+// between the arrival of consecutive chunks. Gaps longer than the filter
+// threshold indicate that data was held in a buffer somewhere along the path;
+// the average gap, multiplied by the chunk size, yields the implied buffer
+// size.
+package kmh
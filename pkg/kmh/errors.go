@@ -0,0 +1,24 @@
+package kmh
+
+import "errors"
+
+var (
+	// ErrCancelled is returned by KmhCalculator.Read once the measurement's
+	// context is done. It marks the normal end of a measurement.
+	ErrCancelled = errors.New("measurement cancelled")
+	// ErrSourceClosed is returned when the source ends its stream before the
+	// measurement is complete.
+	ErrSourceClosed = errors.New("source closed the stream")
+	// ErrShortSample is reported when a measurement records too few deltas
+	// to produce an estimate.
+	ErrShortSample = errors.New("too few deltas were recorded")
+	// ErrConnectTimeout is returned when the source is not opened within the
+	// configured connect timeout.
+	ErrConnectTimeout = errors.New("connect timed out")
+	// ErrReadTimeout is reported when a measurement ends because no data
+	// arrived within the configured read timeout.
+	ErrReadTimeout = errors.New("no data was received within the read timeout")
+	// ErrZeroSize is returned when a measurement is configured with chunks
+	// of no bytes, which have no gaps between them to measure.
+	ErrZeroSize = errors.New("the chunk size must be at least one byte")
+)
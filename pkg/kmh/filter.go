@@ -0,0 +1,30 @@
+package kmh
+
+import (
+	"math"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// madScale makes the median absolute deviation a consistent estimator of the
+// standard deviation of normally distributed values.
+const madScale = 1.4826
+
+// RejectOutliers returns the deltas that lie within threshold scaled median
+// absolute deviations of their median. A delta that differs from the median
+// when the deviation is zero is an outlier. A threshold of zero keeps every
+// delta.
+func RejectOutliers(deltas []int64, threshold float64) []int64 {
+	if threshold <= 0 || len(deltas) == 0 {
+		return deltas
+	}
+	median := stats.Median(deltas)
+	limit := threshold * madScale * stats.MAD(deltas)
+	kept := make([]int64, 0, len(deltas))
+	for _, delta := range deltas {
+		if math.Abs(float64(delta)-median) <= limit {
+			kept = append(kept, delta)
+		}
+	}
+	return kept
+}
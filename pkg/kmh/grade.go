@@ -0,0 +1,27 @@
+package kmh
+
+import "time"
+
+// gradeLimits are the most delay a buffer may add for each grade, after the
+// bufferbloat grades of common speed tests.
+var gradeLimits = []struct {
+	grade string
+	limit time.Duration
+}{
+	{"A+", 5 * time.Millisecond},
+	{"A", 30 * time.Millisecond},
+	{"B", 60 * time.Millisecond},
+	{"C", 200 * time.Millisecond},
+	{"D", 400 * time.Millisecond},
+}
+
+// Grade returns the letter grade, from A+ to F, of a buffer that adds delay
+// to the path.
+func Grade(delay time.Duration) string {
+	for _, limit := range gradeLimits {
+		if delay < limit.limit {
+			return limit.grade
+		}
+	}
+	return "F"
+}
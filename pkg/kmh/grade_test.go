@@ -0,0 +1,44 @@
+package kmh
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueueDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		burst   uint64
+		goodput float64
+		want    time.Duration
+	}{
+		{"single chunk", 100, 100, 0},
+		{"no goodput", 300, 0, 0},
+		{"held chunks", 300, 100, 2 * time.Second},
+		{"partly held", 150, 1000, 50 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := QueueDelay(test.burst, 100, test.goodput); got != test.want {
+			t.Errorf("%v: QueueDelay(%v, 100, %v) = %v, want %v", test.name, test.burst, test.goodput, got, test.want)
+		}
+	}
+}
+
+func TestGrade(t *testing.T) {
+	tests := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{0, "A+"},
+		{5 * time.Millisecond, "A"},
+		{59 * time.Millisecond, "B"},
+		{199 * time.Millisecond, "C"},
+		{399 * time.Millisecond, "D"},
+		{400 * time.Millisecond, "F"},
+	}
+	for _, test := range tests {
+		if got := Grade(test.delay); got != test.want {
+			t.Errorf("Grade(%v) = %v, want %v", test.delay, got, test.want)
+		}
+	}
+}
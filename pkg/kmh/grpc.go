@@ -0,0 +1,134 @@
+package kmh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/grpc"
+)
+
+// GRPCSource streams the payloads of the chunks sent by the Stream method of
+// the gRPC Periodic service at URL. gRPC requires HTTP/2, so Client must
+// negotiate it.
+type GRPCSource struct {
+	Client  *http.Client
+	URL     string
+	Token   string
+	Request grpc.StreamRequest
+}
+
+func (s GRPCSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	message := bytes.Buffer{}
+	grpc.WriteMessage(&message, s.Request.Marshal())
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, &message)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", grpc.ContentType)
+	request.Header.Set("TE", "trailers")
+	if s.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	connection := Connection{}
+	response, err := s.Client.Do(traceAddresses(request, &connection))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected response status: %v", response.Status)
+	}
+	if response.ProtoMajor != 2 {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: gRPC requires HTTP/2 but %v was negotiated", grpc.ErrProtocol, response.Proto)
+	}
+	if err := grpcStatus(response.Header); err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	connection.Protocol = "grpc/" + response.Proto
+	connection.setTLS(response.TLS)
+	connection.Proxy = proxyFor(s.Client, request)
+	return &chunkReader{response: response, connection: connection}, nil
+}
+
+// GRPCURL returns the URL of the Stream method of the gRPC Periodic service
+// at target, a host optionally preceded by an https:// scheme and followed
+// by a path, which is ignored.
+func GRPCURL(target string) (string, error) {
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if parsed.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme: %v", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("invalid URL: no host: %v", target)
+	}
+	return fmt.Sprintf("https://%v%v", parsed.Host, grpc.StreamMethod), nil
+}
+
+// grpcStatus returns the error reported by the Grpc-Status and Grpc-Message
+// fields of header, if any.
+func grpcStatus(header http.Header) error {
+	status := header.Get("Grpc-Status")
+	if status == "" || status == "0" {
+		return nil
+	}
+	message, err := url.PathUnescape(header.Get("Grpc-Message"))
+	if err != nil {
+		message = header.Get("Grpc-Message")
+	}
+	return fmt.Errorf("gRPC call failed with status %v: %v", status, message)
+}
+
+// chunkReader reads the payloads of Chunk messages as a stream.
+type chunkReader struct {
+	response   *http.Response
+	connection Connection
+	buffer     []byte
+	pending    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		message, err := grpc.ReadMessage(r.response.Body, r.buffer)
+		if errors.Is(err, io.EOF) {
+			if err := grpcStatus(r.response.Trailer); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.buffer = message
+		chunk := grpc.Chunk{}
+		if err := chunk.Unmarshal(message); err != nil {
+			return 0, err
+		}
+		r.pending = chunk.Payload
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *chunkReader) Connection() Connection {
+	return r.connection
+}
+
+func (r *chunkReader) Close() error {
+	return r.response.Body.Close()
+}
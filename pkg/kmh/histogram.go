@@ -0,0 +1,73 @@
+package kmh
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// HistogramWidth is the length of the bar drawn for the fullest bucket of a
+// histogram.
+const HistogramWidth = 40
+
+// Histogram returns a textual histogram of deltas, in nanoseconds, counted in
+// the given number of buckets. scale is "linear", for buckets of equal
+// width, or "log", for buckets whose widths grow geometrically.
+func Histogram(deltas []int64, scale string, buckets int) (string, error) {
+	var counted []stats.Bucket
+	switch scale {
+	case "linear":
+		counted = stats.LinearHistogram(deltas, buckets)
+	case "log":
+		counted = stats.LogHistogram(deltas, buckets)
+	default:
+		return "", fmt.Errorf("unknown histogram scale: %v", scale)
+	}
+
+	fullest := 0
+	for _, bucket := range counted {
+		fullest = max(fullest, bucket.Count)
+	}
+
+	histogram := strings.Builder{}
+	for _, bucket := range counted {
+		bar := 0
+		if fullest > 0 {
+			bar = bucket.Count * HistogramWidth / fullest
+		}
+		fmt.Fprintf(&histogram, "%14v - %-14v %5v %v\n",
+			time.Duration(bucket.Low).Round(time.Microsecond), time.Duration(bucket.High).Round(time.Microsecond),
+			bucket.Count, strings.Repeat("#", bar))
+	}
+	return histogram.String(), nil
+}
+
+// sparks are the bars of a sparkline, from shortest to tallest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline returns a line of at most width bars whose heights follow the
+// deltas, in nanoseconds, in the order they were recorded. When there are
+// more deltas than bars, each bar shows the mean of consecutive deltas.
+func Sparkline(deltas []int64, width int) string {
+	if len(deltas) == 0 || width <= 0 {
+		return ""
+	}
+	bars := min(len(deltas), width)
+	heights := make([]float64, bars)
+	for i := range heights {
+		heights[i] = stats.Mean(deltas[i*len(deltas)/bars : (i+1)*len(deltas)/bars])
+	}
+	low, high := stats.Min(heights), stats.Max(heights)
+
+	line := strings.Builder{}
+	for _, height := range heights {
+		spark := 0
+		if high > low {
+			spark = int((height - low) / (high - low) * float64(len(sparks)-1))
+		}
+		line.WriteRune(sparks[spark])
+	}
+	return line.String()
+}
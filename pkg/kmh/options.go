@@ -0,0 +1,94 @@
+package kmh
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Option configures a KmhCalculator or a KmhWriter.
+type Option func(*tracker)
+
+// WithFilter sets the minimum gap between chunks that is recorded as a delta.
+// The default is DefaultFilter. A negative filter records every gap.
+func WithFilter(filter time.Duration) Option {
+	return func(t *tracker) {
+		t.filter = filter
+	}
+}
+
+// WithAdaptiveFilter replaces the fixed filter with one learned from the
+// first samples gaps, which are not recorded: factor times their median.
+// With a factor below one, such as 0.5, gaps much shorter than the server's
+// pacing interval are filtered, whatever that interval is.
+func WithAdaptiveFilter(samples int, factor float64) Option {
+	return func(t *tracker) {
+		t.learn = samples
+		t.factor = factor
+	}
+}
+
+// WithWarmup discards the deltas completed within warmup of the start of the
+// measurement, while the connection is set up and ramps up.
+func WithWarmup(warmup time.Duration) Option {
+	return func(t *tracker) {
+		t.warmup = warmup
+	}
+}
+
+// WithSkipFirst discards the first n deltas.
+func WithSkipFirst(n int) Option {
+	return func(t *tracker) {
+		t.skip = n
+	}
+}
+
+// WithSpreadCoalesced spreads the arrival times of chunks that complete in
+// the same read over the time since the read before, in proportion to their
+// bytes, rather than giving them all the time of the read. This compensates
+// for segmentation offload, which otherwise collapses their gaps to zero.
+func WithSpreadCoalesced(spread bool) Option {
+	return func(t *tracker) {
+		t.spread = spread
+	}
+}
+
+// WithDebug enables tracing of every read or write, and of the filter's
+// decisions, on standard output.
+func WithDebug(debug bool) Option {
+	return func(t *tracker) {
+		if debug {
+			t.logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: LevelTrace}))
+		}
+	}
+}
+
+// WithLogger logs the filter's decisions to logger at slog.LevelDebug and
+// every read or write at LevelTrace.
+func WithLogger(logger *slog.Logger) Option {
+	return func(t *tracker) {
+		t.logger = logger
+	}
+}
+
+// WithClock sets the function used to tell the time. The default is
+// time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(t *tracker) {
+		t.now = now
+	}
+}
+
+// WithSize sets the size of the chunks sent by the server.
+func WithSize(size uint64) Option {
+	return func(t *tracker) {
+		t.size = size
+	}
+}
+
+// WithOnDelta registers f to be called each time a delta is recorded.
+func WithOnDelta(f func(d time.Duration, ts time.Time)) Option {
+	return func(t *tracker) {
+		t.onDelta = f
+	}
+}
@@ -0,0 +1,115 @@
+package kmh
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"unicode"
+)
+
+var (
+	rasterBlack = color.RGBA{A: 0xff}
+	rasterWhite = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	rasterBlue  = color.RGBA{R: 0x46, G: 0x82, B: 0xb4, A: 0xff}
+)
+
+// rasterScale is the size, in pixels, of each dot of the raster font.
+const rasterScale = 2
+
+// rasterFont is a font of 3 by 5 dots for the characters charts need, given
+// row by row from the top. Lower case letters are drawn in upper case and
+// characters without a glyph are left blank.
+var rasterFont = map[rune]string{
+	'0': "111101101101111", '1': "010110010010111", '2': "111001111100111", '3': "111001111001111",
+	'4': "101101111001001", '5': "111100111001111", '6': "111100111101111", '7': "111001001001001",
+	'8': "111101111101111", '9': "111101111001111",
+	'A': "010101111101101", 'B': "110101110101110", 'C': "011100100100011", 'D': "110101101101110",
+	'E': "111100110100111", 'F': "111100110100100", 'G': "011100101101011", 'H': "101101111101101",
+	'I': "111010010010111", 'J': "001001001101010", 'K': "101101110101101", 'L': "100100100100111",
+	'M': "101111111101101", 'N': "110101101101101", 'O': "010101101101010", 'P': "110101110100100",
+	'Q': "010101101110011", 'R': "110101110101101", 'S': "011100010001110", 'T': "111010010010010",
+	'U': "101101101101111", 'V': "101101101101010", 'W': "101101111111101", 'X': "101101010101101",
+	'Y': "101101010010010", 'Z': "111001010100111",
+	'.': "000000000000010", '-': "000000111000000", 'µ': "101101101111100",
+}
+
+// raster draws a chart in pixels.
+type raster struct {
+	image *image.RGBA
+}
+
+// newRaster returns a white raster of the given size.
+func newRaster(width, height int) *raster {
+	r := &raster{image: image.NewRGBA(image.Rect(0, 0, width, height))}
+	r.fill(0, 0, float64(width), float64(height), rasterWhite)
+	return r
+}
+
+// fill paints the rectangle at x, y of the given size with c.
+func (r *raster) fill(x, y, width, height float64, c color.RGBA) {
+	for py := int(math.Round(y)); py < int(math.Round(y+height)); py++ {
+		for px := int(math.Round(x)); px < int(math.Round(x+width)); px++ {
+			r.image.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// stroke paints a line from x1, y1 to x2, y2 with c, a pixel at a time.
+func (r *raster) stroke(x1, y1, x2, y2 float64, c color.RGBA) {
+	steps := max(math.Abs(x2-x1), math.Abs(y2-y1), 1)
+	for i := 0.0; i <= steps; i++ {
+		r.image.SetRGBA(int(math.Round(x1+(x2-x1)*i/steps)), int(math.Round(y1+(y2-y1)*i/steps)), c)
+	}
+}
+
+func (r *raster) line(x1, y1, x2, y2 float64) {
+	r.stroke(x1, y1, x2, y2, rasterBlack)
+}
+
+func (r *raster) polyline(points [][2]float64) {
+	for i := 1; i < len(points); i++ {
+		r.stroke(points[i-1][0], points[i-1][1], points[i][0], points[i][1], rasterBlue)
+	}
+}
+
+func (r *raster) point(x, y float64) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			if dx*dx+dy*dy <= 4 {
+				r.image.SetRGBA(int(math.Round(x))+dx, int(math.Round(y))+dy, rasterBlue)
+			}
+		}
+	}
+}
+
+func (r *raster) bar(x, y, width, height float64) {
+	// A pixel is left white on either side to separate the bars.
+	r.fill(x+1, y, width-2, height, rasterBlue)
+}
+
+func (r *raster) text(x, y float64, s string, end bool, bold bool) {
+	glyphs := []rune(s)
+	// Glyphs are a pixel apart, so that labels fit the margins.
+	advance := float64(3*rasterScale + 1)
+	if end {
+		x -= float64(len(glyphs))*advance - 1
+	}
+	top := y - 5*rasterScale
+	for i, glyph := range glyphs {
+		dots, ok := rasterFont[glyph]
+		if !ok {
+			dots = rasterFont[unicode.ToUpper(glyph)]
+		}
+		left := x + float64(i)*advance
+		for dot, on := range dots {
+			if on != '1' {
+				continue
+			}
+			width := float64(rasterScale)
+			if bold {
+				width++
+			}
+			r.fill(left+float64(dot%3*rasterScale), top+float64(dot/3*rasterScale), width, rasterScale, rasterBlack)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package kmh
+
+import (
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// Regression is a line fit to the bytes received by the time of each
+// recorded delta, which cross-checks the estimate made from the deltas
+// themselves.
+type Regression struct {
+	// Rate is the slope of the line, in bytes per second, and Intercept the
+	// bytes it predicts had been received when the first delta was recorded.
+	Rate      float64
+	Intercept float64
+	// R2 is the coefficient of determination of the fit. Values near 1 mean
+	// the bytes arrived in regular steps.
+	R2 float64
+	// Estimate is the time the fit takes to deliver the mean number of bytes
+	// between recorded deltas, and ImpliedBufferBytes the estimate, in
+	// seconds, multiplied by the chunk size.
+	Estimate           time.Duration
+	ImpliedBufferBytes float64
+}
+
+type jsonRegression struct {
+	Rate               float64 `json:"rate_bytes_per_second"`
+	Intercept          float64 `json:"intercept_bytes"`
+	R2                 float64 `json:"r2"`
+	Estimate           int64   `json:"estimate_ns"`
+	ImpliedBufferBytes float64 `json:"implied_buffer_bytes"`
+}
+
+// Regress fits a line to the cumulative bytes received at each recorded
+// delta over time. It returns nil when fewer than three deltas were recorded
+// or no bytes arrived between them.
+func Regress(events []DeltaEvent, size uint64) *Regression {
+	if len(events) < 3 {
+		return nil
+	}
+	seconds := make([]float64, len(events))
+	bytes := make([]uint64, len(events))
+	for i, event := range events {
+		seconds[i] = event.At.Sub(events[0].At).Seconds()
+		bytes[i] = event.Bytes
+	}
+	rate, intercept, r2 := stats.LinearFit(seconds, bytes)
+	if !(rate > 0) {
+		return nil
+	}
+	step := float64(bytes[len(bytes)-1]-bytes[0]) / float64(len(bytes)-1)
+	estimate := time.Duration(step / rate * float64(time.Second))
+	return &Regression{
+		Rate:               rate,
+		Intercept:          intercept,
+		R2:                 r2,
+		Estimate:           estimate,
+		ImpliedBufferBytes: estimate.Seconds() * float64(size),
+	}
+}
+
+func (r *Regression) json() *jsonRegression {
+	if r == nil {
+		return nil
+	}
+	return &jsonRegression{Rate: r.Rate, Intercept: r.Intercept, R2: r.R2, Estimate: r.Estimate.Nanoseconds(), ImpliedBufferBytes: r.ImpliedBufferBytes}
+}
@@ -0,0 +1,30 @@
+package kmh
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegress(t *testing.T) {
+	regular := events(repeat(time.Second, 5), 1000)
+	if regression := Regress(regular, 1000); regression == nil {
+		t.Errorf("Regress() of regular deltas = nil")
+	} else if !near(regression.Rate, 1000) || !near(regression.R2, 1) ||
+		regression.Estimate != time.Second || !near(regression.ImpliedBufferBytes, 1000) {
+		t.Errorf("Regress() of regular deltas = %+v, want 1000 B/s, R² 1, 1s and 1000 bytes", *regression)
+	}
+
+	tests := []struct {
+		name   string
+		events []DeltaEvent
+	}{
+		{"empty", nil},
+		{"too few", events(repeat(time.Second, 2), 1000)},
+		{"no bytes", events(repeat(time.Second, 5), 0)},
+	}
+	for _, test := range tests {
+		if regression := Regress(test.events, 1000); regression != nil {
+			t.Errorf("%v: Regress() = %+v, want nil", test.name, *regression)
+		}
+	}
+}
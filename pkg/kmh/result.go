@@ -0,0 +1,382 @@
+package kmh
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// Result is the outcome of a measurement.
+type Result struct {
+	// Deltas are the recorded gaps between chunks, in nanoseconds.
+	Deltas []int64
+	// Events are the recorded gaps along with when they occurred.
+	Events []DeltaEvent
+	// Samples is the number of recorded gaps.
+	Samples int
+	// Filter is the minimum gap that was recorded as a delta. It is zero if
+	// an adaptive filter was never learned.
+	Filter time.Duration
+	// Discarded is the number of deltas discarded during the warm-up.
+	Discarded int
+	// Paced summarizes the chunks that arrived as the server paced them, and
+	// Drained those that arrived close behind another, drained from a
+	// buffer.
+	Paced   DeliveryClass
+	Drained DeliveryClass
+	// MaxBurst is the most bytes delivered in a single burst of drained
+	// chunks, a bound on the buffer size that does not depend on the deltas.
+	MaxBurst uint64
+	// Coalesced is the number of chunks that completed in the same read as
+	// an earlier one. When it is not zero, segmentation offload may have
+	// collapsed their gaps; see Config.SpreadCoalesced.
+	Coalesced int
+	// Percentiles are the configured percentiles of the deltas.
+	Percentiles []DeltaPercentile
+	// StdDev is the sample standard deviation of the deltas, and Jitter the
+	// mean absolute difference between successive deltas. Both are zero
+	// when fewer than two deltas were recorded.
+	StdDev time.Duration
+	Jitter time.Duration
+	// CV is the coefficient of variation of the deltas, their standard
+	// deviation divided by their mean. HighVariation is set when it exceeds
+	// HighVariationCV, when the deltas vary too much for the implied buffer
+	// size to be trusted.
+	CV            float64
+	HighVariation bool
+	// Skewness and Kurtosis are the sample skewness and excess kurtosis of
+	// the deltas. A long upper tail, as deep buffers produce, makes both
+	// positive. They are zero when fewer than three deltas were recorded or
+	// the deltas are all equal.
+	Skewness float64
+	Kurtosis float64
+	// Rejected is the number of deltas excluded from the estimate as
+	// outliers.
+	Rejected int
+	// Mean and HarmonicMean are the arithmetic and harmonic means of the
+	// deltas, reported whatever the Statistic.
+	Mean         time.Duration
+	HarmonicMean time.Duration
+	// Estimate is the aggregate of the deltas computed by the configured
+	// Statistic. It alone feeds ImpliedBufferBytes.
+	Estimate time.Duration
+	// Uncertainty is the standard deviation of the estimate, if the
+	// Statistic is Uncertain.
+	Uncertainty time.Duration
+	// Goodput is the mean rate, in bytes per second, at which data was
+	// received over the measurement.
+	Goodput float64
+	// ImpliedBufferBytes is the implied buffer size, in bytes: the estimate,
+	// in seconds, multiplied by the chunk size in bytes.
+	ImpliedBufferBytes float64
+	// ImpliedBufferSizes describes the implied buffer sizes, in bytes,
+	// computed from each delta used for the estimate, rather than from their
+	// aggregate.
+	ImpliedBufferSizes ImpliedRange
+	// BufferDelay is the delay the buffer added to the chunks it held: the
+	// time the longest burst, past its first chunk, takes to drain at
+	// Goodput. Grade is its letter grade. Both are empty when no chunks or no
+	// data were received.
+	BufferDelay time.Duration
+	Grade       string
+	// Interval bounds the 95% confidence interval for the mean of the deltas
+	// used for the estimate, and ImpliedBufferInterval the corresponding
+	// implied buffer sizes. Both are zero when fewer than two deltas were
+	// used.
+	Interval              [2]time.Duration
+	ImpliedBufferInterval [2]float64
+	// LowConfidence is set when fewer than LowConfidenceSamples deltas were
+	// used for the estimate.
+	LowConfidence bool
+	// Regression is a line fit to the bytes received over time, an
+	// alternate estimate of the delta. It is nil when fewer than three deltas
+	// were recorded.
+	Regression *Regression
+	// Segments are the runs of deltas between the change points detected
+	// during the measurement, each with its own estimate, when change-point
+	// detection was requested.
+	Segments []Segment
+	// Duration is how long the measurement ran.
+	Duration time.Duration
+	// Throughput is the goodput achieved in each second of the measurement.
+	Throughput []ThroughputInterval
+	// Extended is set when the measurement ran past its timeout to record
+	// the minimum number of deltas.
+	Extended bool
+	// Errors are the non-fatal errors encountered while reading.
+	Errors []error
+	// Connection describes how the stream reached the measurement, when the
+	// source can tell.
+	Connection Connection
+	// Datagrams counts the datagrams received, when the stream was carried
+	// over UDP.
+	Datagrams *DatagramStats
+}
+
+// ImpliedRange summarizes a distribution of implied buffer sizes by its
+// quartiles and extremes.
+type ImpliedRange struct {
+	Min    float64 `json:"min"`
+	Q1     float64 `json:"q1"`
+	Median float64 `json:"median"`
+	Q3     float64 `json:"q3"`
+	Max    float64 `json:"max"`
+}
+
+// IQR returns the interquartile range of the sizes.
+func (r ImpliedRange) IQR() float64 {
+	return r.Q3 - r.Q1
+}
+
+// DeltaPercentile is the Pth percentile of the deltas.
+type DeltaPercentile struct {
+	P     float64
+	Delta time.Duration
+}
+
+type jsonPercentile struct {
+	P     float64 `json:"p"`
+	Delta int64   `json:"delta_ns"`
+}
+
+// HighVariationCV is the coefficient of variation of the deltas above which
+// a result is marked as varying too much.
+const HighVariationCV = 0.25
+
+// LowConfidenceSamples is the number of deltas below which an estimate is
+// marked as of low confidence.
+const LowConfidenceSamples = 10
+
+type jsonResult struct {
+	Samples            int                      `json:"samples"`
+	Filter             int64                    `json:"filter_ns"`
+	Discarded          int                      `json:"discarded"`
+	Paced              jsonDeliveryClass        `json:"paced"`
+	Drained            jsonDeliveryClass        `json:"drained"`
+	MaxBurst           uint64                   `json:"max_burst_bytes"`
+	Coalesced          int                      `json:"coalesced"`
+	Percentiles        []jsonPercentile         `json:"percentiles,omitempty"`
+	StdDev             int64                    `json:"stddev_ns"`
+	Jitter             int64                    `json:"jitter_ns"`
+	CV                 float64                  `json:"cv"`
+	HighVariation      bool                     `json:"high_variation"`
+	Skewness           float64                  `json:"skewness"`
+	Kurtosis           float64                  `json:"kurtosis"`
+	Rejected           int                      `json:"rejected"`
+	Mean               int64                    `json:"mean_ns"`
+	HarmonicMean       int64                    `json:"harmonic_mean_ns"`
+	Estimate           int64                    `json:"estimate_ns"`
+	Uncertainty        int64                    `json:"uncertainty_ns,omitempty"`
+	Goodput            float64                  `json:"goodput_bytes_per_second"`
+	ImpliedBufferBytes float64                  `json:"implied_buffer_bytes"`
+	ImpliedSizes       ImpliedRange             `json:"implied_buffer_sizes"`
+	BufferDelay        int64                    `json:"buffer_delay_ns"`
+	Grade              string                   `json:"grade,omitempty"`
+	Interval           [2]int64                 `json:"interval_ns"`
+	ImpliedInterval    [2]float64               `json:"implied_buffer_interval"`
+	LowConfidence      bool                     `json:"low_confidence"`
+	Regression         *jsonRegression          `json:"regression,omitempty"`
+	Segments           []jsonSegment            `json:"segments,omitempty"`
+	Duration           int64                    `json:"duration_ns"`
+	Throughput         []jsonThroughputInterval `json:"throughput"`
+	Extended           bool                     `json:"extended"`
+	Deltas             []int64                  `json:"deltas_ns"`
+	Events             []DeltaEvent             `json:"events"`
+	Errors             []string                 `json:"errors,omitempty"`
+	Connection         Connection               `json:"connection"`
+	Datagrams          *DatagramStats           `json:"datagrams,omitempty"`
+}
+
+// MarshalJSON encodes the result with durations in nanoseconds and errors as
+// their messages.
+func (r Result) MarshalJSON() ([]byte, error) {
+	encoded := jsonResult{
+		Samples:            r.Samples,
+		Filter:             r.Filter.Nanoseconds(),
+		Discarded:          r.Discarded,
+		Paced:              r.Paced.json(),
+		Drained:            r.Drained.json(),
+		MaxBurst:           r.MaxBurst,
+		Coalesced:          r.Coalesced,
+		StdDev:             r.StdDev.Nanoseconds(),
+		Jitter:             r.Jitter.Nanoseconds(),
+		CV:                 r.CV,
+		HighVariation:      r.HighVariation,
+		Skewness:           r.Skewness,
+		Kurtosis:           r.Kurtosis,
+		Rejected:           r.Rejected,
+		Mean:               r.Mean.Nanoseconds(),
+		HarmonicMean:       r.HarmonicMean.Nanoseconds(),
+		Estimate:           r.Estimate.Nanoseconds(),
+		Uncertainty:        r.Uncertainty.Nanoseconds(),
+		Goodput:            r.Goodput,
+		ImpliedBufferBytes: r.ImpliedBufferBytes,
+		ImpliedSizes:       r.ImpliedBufferSizes,
+		BufferDelay:        r.BufferDelay.Nanoseconds(),
+		Grade:              r.Grade,
+		Interval:           [2]int64{r.Interval[0].Nanoseconds(), r.Interval[1].Nanoseconds()},
+		ImpliedInterval:    r.ImpliedBufferInterval,
+		LowConfidence:      r.LowConfidence,
+		Regression:         r.Regression.json(),
+		Duration:           r.Duration.Nanoseconds(),
+		Extended:           r.Extended,
+		Deltas:             r.Deltas,
+		Events:             r.Events,
+		Connection:         r.Connection,
+		Datagrams:          r.Datagrams,
+	}
+	for _, p := range r.Percentiles {
+		encoded.Percentiles = append(encoded.Percentiles, jsonPercentile{P: p.P, Delta: p.Delta.Nanoseconds()})
+	}
+	for _, interval := range r.Throughput {
+		encoded.Throughput = append(encoded.Throughput, jsonThroughputInterval{
+			Start: interval.Start.Nanoseconds(),
+			End:   interval.End.Nanoseconds(),
+			Bytes: interval.Bytes,
+			Rate:  interval.Rate(),
+		})
+	}
+	for _, segment := range r.Segments {
+		encoded.Segments = append(encoded.Segments, jsonSegment{
+			Start:              segment.Start,
+			End:                segment.End,
+			Samples:            segment.Samples,
+			Estimate:           segment.Estimate.Nanoseconds(),
+			ImpliedBufferBytes: segment.ImpliedBufferBytes,
+		})
+	}
+	for _, err := range r.Errors {
+		encoded.Errors = append(encoded.Errors, err.Error())
+	}
+	return json.Marshal(encoded)
+}
+
+// String returns a human-readable summary of the result with sizes in
+// bytes.
+func (r Result) String() string {
+	return r.Format(Bytes)
+}
+
+// Format returns a human-readable summary of the result with sizes in unit.
+func (r Result) Format(unit Unit) string {
+	summary := strings.Builder{}
+	if r.Connection.Protocol != "" {
+		fmt.Fprintf(&summary, "Negotiated protocol                       : %v\n", r.Connection.Protocol)
+	}
+	if r.Connection.TLSVersion != "" {
+		fmt.Fprintf(&summary, "Negotiated TLS version                    : %v\n", r.Connection.TLSVersion)
+	}
+	if r.Connection.RemoteAddress != "" {
+		fmt.Fprintf(&summary, "Remote address                            : %v (%v)\n", r.Connection.RemoteAddress, r.Connection.Family)
+	}
+	if r.Connection.LocalAddress != "" {
+		fmt.Fprintf(&summary, "Local address                             : %v\n", r.Connection.LocalAddress)
+	}
+	if r.Connection.RTT != 0 {
+		fmt.Fprintf(&summary, "Baseline RTT                              : %v\n", r.Connection.RTT)
+	}
+	if r.Connection.MPTCP != nil {
+		fmt.Fprintf(&summary, "Multipath TCP negotiated?                 : %v\n", *r.Connection.MPTCP)
+	}
+	if r.Connection.Proxy != "" {
+		fmt.Fprintf(&summary, "Proxy                                     : %v\n", r.Connection.Proxy)
+	}
+	if r.Extended {
+		fmt.Fprintf(&summary, "Measurement duration                      : %v (extended)\n", r.Duration)
+	} else {
+		fmt.Fprintf(&summary, "Measurement duration                      : %v\n", r.Duration)
+	}
+	fmt.Fprintf(&summary, "Delta filter                              : %v\n", r.Filter)
+	if r.Discarded > 0 {
+		fmt.Fprintf(&summary, "Deltas discarded during warm-up           : %v\n", r.Discarded)
+	}
+	fmt.Fprintf(&summary, "Deltas recorded                           : %v\n", r.Samples)
+	for _, class := range []struct {
+		name  string
+		class DeliveryClass
+	}{{"Paced", r.Paced}, {"Drained", r.Drained}} {
+		if class.class.Chunks > 0 {
+			fmt.Fprintf(&summary, "%-42v: %v (%v), mean gap %v, median gap %v\n", class.name+" chunks",
+				class.class.Chunks, unit.Format(float64(class.class.Bytes)), class.class.MeanGap, class.class.MedianGap)
+		}
+	}
+	if r.Coalesced > 0 {
+		fmt.Fprintf(&summary, "Chunks completed by a read with another   : %v\n", r.Coalesced)
+	}
+	if r.MaxBurst > 0 {
+		fmt.Fprintf(&summary, "Max observed burst                        : %v\n", unit.Format(float64(r.MaxBurst)))
+	}
+	if r.Samples > 0 {
+		fmt.Fprintf(&summary, "Minimum delta                             : %v\n", time.Duration(stats.Min(r.Deltas)))
+		fmt.Fprintf(&summary, "Median delta                              : %v\n", time.Duration(stats.Median(r.Deltas)))
+		fmt.Fprintf(&summary, "Maximum delta                             : %v\n", time.Duration(stats.Max(r.Deltas)))
+		fmt.Fprintf(&summary, "Mean delta                                : %v\n", r.Mean)
+		fmt.Fprintf(&summary, "Harmonic mean delta                       : %v\n", r.HarmonicMean)
+	}
+	for _, p := range r.Percentiles {
+		fmt.Fprintf(&summary, "%-42v: %v\n", fmt.Sprintf("p%v delta", p.P), p.Delta)
+	}
+	if r.Samples > 1 {
+		fmt.Fprintf(&summary, "Delta standard deviation                  : %v\n", r.StdDev)
+		fmt.Fprintf(&summary, "Delta jitter                              : %v\n", r.Jitter)
+		fmt.Fprintf(&summary, "Delta coefficient of variation            : %.3f\n", r.CV)
+	}
+	if r.Samples > 2 {
+		fmt.Fprintf(&summary, "Delta skewness                            : %.3f\n", r.Skewness)
+		fmt.Fprintf(&summary, "Delta excess kurtosis                     : %.3f\n", r.Kurtosis)
+	}
+	if r.Datagrams != nil {
+		fmt.Fprintf(&summary, "Datagrams received                        : %v\n", r.Datagrams.Received)
+		fmt.Fprintf(&summary, "Datagrams lost                            : %v\n", r.Datagrams.Lost)
+		fmt.Fprintf(&summary, "Datagrams reordered                       : %v\n", r.Datagrams.Reordered)
+		fmt.Fprintf(&summary, "Datagrams duplicated                      : %v\n", r.Datagrams.Duplicated)
+	}
+	if r.Rejected > 0 {
+		fmt.Fprintf(&summary, "Outliers excluded from estimate           : %v\n", r.Rejected)
+	}
+	if r.Uncertainty != 0 {
+		fmt.Fprintf(&summary, "Estimated delta                           : %v ± %v\n", r.Estimate, r.Uncertainty)
+	} else {
+		fmt.Fprintf(&summary, "Estimated delta                           : %v\n", r.Estimate)
+	}
+	if r.Interval != [2]time.Duration{} {
+		fmt.Fprintf(&summary, "95%% confidence interval of mean delta     : %v - %v\n", r.Interval[0], r.Interval[1])
+		fmt.Fprintf(&summary, "95%% confidence interval of buffer size    : %v - %v\n", unit.Format(r.ImpliedBufferInterval[0]), unit.Format(r.ImpliedBufferInterval[1]))
+	}
+	if sizes := r.ImpliedBufferSizes; sizes != (ImpliedRange{}) {
+		fmt.Fprintf(&summary, "Per-delta implied buffer sizes            : %v / %v / %v (min / median / max), IQR %v\n",
+			unit.Format(sizes.Min), unit.Format(sizes.Median), unit.Format(sizes.Max), unit.Format(sizes.IQR()))
+	}
+	if r.Regression != nil {
+		fmt.Fprintf(&summary, "Regression estimated delta                : %v (%v, R² %.3f)\n", r.Regression.Estimate, unit.Format(r.Regression.ImpliedBufferBytes), r.Regression.R2)
+	}
+	if len(r.Segments) > 1 {
+		for i, segment := range r.Segments {
+			fmt.Fprintf(&summary, "%-42v: %v to %v, %v deltas, %v (%v)\n", fmt.Sprintf("Segment %v", i+1),
+				segment.Start.Format(time.TimeOnly), segment.End.Format(time.TimeOnly), segment.Samples, segment.Estimate, unit.Format(segment.ImpliedBufferBytes))
+		}
+	}
+	if r.HighVariation {
+		fmt.Fprintf(&summary, "warning: high variation: the deltas' coefficient of variation exceeds %v.\n", HighVariationCV)
+	}
+	if r.LowConfidence {
+		fmt.Fprintf(&summary, "warning: low confidence: fewer than %v deltas were used for the estimate.\n", LowConfidenceSamples)
+	}
+	for _, err := range r.Errors {
+		fmt.Fprintf(&summary, "error: %v.\n", err)
+	}
+	if r.Grade != "" {
+		// The RTT is the delay of the unloaded path, against which the
+		// queueing delay the buffer adds under load is compared.
+		fmt.Fprintf(&summary, "Queueing delay added by the buffer        : %v (grade %v", r.BufferDelay.Round(time.Microsecond), r.Grade)
+		if r.Connection.RTT != 0 {
+			fmt.Fprintf(&summary, ", %.1f× baseline RTT", r.BufferDelay.Seconds()/r.Connection.RTT.Seconds())
+		}
+		summary.WriteString(")\n")
+	}
+	fmt.Fprintf(&summary, "KMH Implied Buffer Size: %v", unit.Format(r.ImpliedBufferBytes))
+	return summary.String()
+}
@@ -0,0 +1,131 @@
+package kmh
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Config describes a measurement.
+type Config struct {
+	// URL is the host and path of the periodic endpoint, optionally preceded
+	// by an http:// or https:// scheme. Without a scheme, https is used.
+	URL string
+	// Size is the amount of data periodically sent from the server.
+	Size uint64
+	// Client, if not nil, is used to issue the GET request. Otherwise, a
+	// client is created with NewClient using Buffer and Insecure.
+	Client *http.Client
+	// Buffer is the size of the local read buffer.
+	Buffer int
+	// Insecure allows the server to have a self-signed certificate.
+	Insecure bool
+	// Token, if not empty, is presented to the server as a bearer token.
+	Token string
+	// Timeout is how long the measurement lasts.
+	Timeout time.Duration
+	// ConnectTimeout, if not zero, is how long opening the source, including
+	// any TLS handshake and the response to the request, may take.
+	ConnectTimeout time.Duration
+	// ReadTimeout, if not zero, ends the measurement early when no data has
+	// arrived for this long.
+	ReadTimeout time.Duration
+	// MinSamples is the number of deltas the measurement should record. If
+	// fewer have been recorded when Timeout elapses, the measurement is
+	// extended until they have been or until it has lasted MaxDuration.
+	MinSamples  int
+	MaxDuration time.Duration
+	// Transport is how the stream is carried from URL: TransportHTTP, the
+	// default, TransportWebSocket, TransportGRPC, TransportTCP or
+	// TransportUDP. With the raw transports, URL is the host and port of a
+	// raw TCP or UDP sender. TransportGRPC requires a Client that speaks
+	// HTTP/2.
+	Transport string
+	// Dial, if not nil, makes the connections of the raw transports, as
+	// Client does for the others. See ClientConfig.Dialer.
+	Dial func(ctx context.Context, network, address string) (net.Conn, error)
+	// Source, if not nil, is used instead of a source built for Transport.
+	Source Source
+	// Statistic aggregates the deltas into the estimate used to compute the
+	// implied buffer size. It must not be shared between runs. If nil, the
+	// mean is used.
+	Statistic Statistic
+	// Filter is the minimum gap between chunks recorded as a delta. If zero,
+	// DefaultFilter is used; if negative, every gap is recorded. It must be
+	// shorter than the server's interval for any delta to be recorded.
+	Filter time.Duration
+	// AdaptiveSamples, if not zero, replaces Filter with one learned from
+	// the first AdaptiveSamples gaps: AdaptiveFactor times their median.
+	AdaptiveSamples int
+	AdaptiveFactor  float64
+	// Warmup and SkipFirst discard the deltas completed within Warmup of the
+	// start of the measurement and the first SkipFirst deltas, which are
+	// disturbed by connection setup and slow start.
+	Warmup    time.Duration
+	SkipFirst int
+	// SpreadCoalesced spreads the arrival times of chunks that complete in
+	// the same read. See WithSpreadCoalesced.
+	SpreadCoalesced bool
+	// OutlierThreshold, if not zero, excludes from the estimate the deltas
+	// more than this many scaled median absolute deviations from the median.
+	// A threshold of 3 is typical.
+	OutlierThreshold float64
+	// ChangePointThreshold, if not zero, splits the deltas at the points
+	// where their mean shifts, as when cross traffic starts mid-test, and
+	// reports an estimate for each segment. See Segments.
+	ChangePointThreshold float64
+	// Percentiles are the percentiles of the deltas, between 0 and 100, to
+	// report in the Result. If nil, DefaultPercentiles are reported.
+	Percentiles []float64
+	// Logger, if not nil, receives the filter's decisions and a trace of
+	// every read. See WithLogger.
+	Logger *slog.Logger
+	// OnDelta, if not nil, is called each time a delta is recorded.
+	OnDelta func(d time.Duration, ts time.Time)
+	// OnEstimate, if not nil, is called each time a delta is recorded with
+	// a running estimate: the exponentially weighted moving average of the
+	// deltas so far, weighted by LiveAlpha.
+	OnEstimate func(estimate time.Duration, ts time.Time)
+	// OnInterim, if not nil, is called every InterimInterval while the
+	// measurement runs with its progress so far.
+	OnInterim       func(interim Interim)
+	InterimInterval time.Duration
+	// LiveAlpha is the weight given to each new delta by the running
+	// estimate. If zero, DefaultEWMAAlpha is used.
+	LiveAlpha float64
+}
+
+// Interim is the progress of a measurement that is still running.
+type Interim struct {
+	// Elapsed is how long the measurement has run.
+	Elapsed time.Duration
+	// Samples is the number of deltas recorded so far, and Estimate their
+	// mean.
+	Samples  int
+	Estimate time.Duration
+	// Bytes is the number of bytes received so far.
+	Bytes uint64
+}
+
+// DefaultAdaptiveFactor is the factor of an adaptive filter when a Config
+// does not choose one.
+const DefaultAdaptiveFactor = 0.5
+
+// DefaultPercentiles are the percentiles of the deltas reported, alongside
+// the median, when a Config does not choose any.
+var DefaultPercentiles = []float64{90, 95, 99}
+
+// Run connects to the periodic endpoint described by config, measures for
+// config.Timeout and returns the result. An error is returned only when the
+// measurement could not be started.
+func Run(ctx context.Context, config Config) (Result, error) {
+	session := NewSession(config)
+	defer session.Close()
+
+	if err := session.Connect(ctx); err != nil {
+		return Result{}, err
+	}
+	return session.Measure(ctx)
+}
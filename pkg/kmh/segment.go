@@ -0,0 +1,64 @@
+package kmh
+
+import (
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// Segment is a run of deltas between two change points, over which the
+// path's behaviour appeared steady.
+type Segment struct {
+	// Start and End are when the first and last deltas of the segment were
+	// recorded.
+	Start time.Time
+	End   time.Time
+	// Samples is the number of deltas in the segment.
+	Samples int
+	// Estimate is the mean of the segment's deltas, and ImpliedBufferBytes the
+	// estimate, in seconds, multiplied by the chunk size.
+	Estimate           time.Duration
+	ImpliedBufferBytes float64
+}
+
+type jsonSegment struct {
+	Start              time.Time `json:"start"`
+	End                time.Time `json:"end"`
+	Samples            int       `json:"samples"`
+	Estimate           int64     `json:"estimate_ns"`
+	ImpliedBufferBytes float64   `json:"implied_buffer_bytes"`
+}
+
+// MinSegmentSamples is the fewest deltas in a segment found by Segments.
+const MinSegmentSamples = 5
+
+// Segments splits the recorded deltas at the change points whose CUSUM
+// statistic exceeds threshold and estimates each segment separately for
+// chunks of size bytes. A threshold of about 1.36 flags shifts unlikely to be
+// noise; larger thresholds find fewer, clearer changes. When no change is
+// found, a single segment spanning every delta is returned.
+func Segments(events []DeltaEvent, threshold float64, size uint64) []Segment {
+	if len(events) == 0 {
+		return nil
+	}
+	gaps := make([]time.Duration, len(events))
+	for i, event := range events {
+		gaps[i] = event.Gap
+	}
+	points := append(stats.ChangePoints(gaps, threshold, MinSegmentSamples), len(events))
+
+	segments := make([]Segment, 0, len(points))
+	start := 0
+	for _, end := range points {
+		estimate := time.Duration(stats.Mean(gaps[start:end]))
+		segments = append(segments, Segment{
+			Start:              events[start].At,
+			End:                events[end-1].At,
+			Samples:            end - start,
+			Estimate:           estimate,
+			ImpliedBufferBytes: estimate.Seconds() * float64(size),
+		})
+		start = end
+	}
+	return segments
+}
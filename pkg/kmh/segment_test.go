@@ -0,0 +1,73 @@
+package kmh
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// near reports whether got and want are within a small tolerance of each
+// other.
+func near(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*max(1, math.Abs(want))
+}
+
+// events returns a delta event for each of gaps, each completing a chunk of
+// size bytes.
+func events(gaps []time.Duration, size uint64) []DeltaEvent {
+	at := time.Unix(0, 0)
+	recorded := make([]DeltaEvent, len(gaps))
+	for i, gap := range gaps {
+		at = at.Add(gap)
+		recorded[i] = DeltaEvent{At: at, Gap: gap, Bytes: uint64(i+1) * size}
+	}
+	return recorded
+}
+
+// repeat returns n copies of gap.
+func repeat(gap time.Duration, n int) []time.Duration {
+	gaps := make([]time.Duration, n)
+	for i := range gaps {
+		gaps[i] = gap
+	}
+	return gaps
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		name      string
+		gaps      []time.Duration
+		estimates []time.Duration
+	}{
+		{"empty", nil, nil},
+		{"constant", repeat(100*time.Millisecond, 10), []time.Duration{100 * time.Millisecond}},
+		{"step", append(repeat(100*time.Millisecond, 10), repeat(300*time.Millisecond, 10)...),
+			[]time.Duration{100 * time.Millisecond, 300 * time.Millisecond}},
+		{"step too short to split", append(repeat(100*time.Millisecond, 4), repeat(300*time.Millisecond, 4)...),
+			[]time.Duration{200 * time.Millisecond}},
+	}
+	for _, test := range tests {
+		recorded := events(test.gaps, 1000)
+		segments := Segments(recorded, 1.36, 1000)
+		if len(segments) != len(test.estimates) {
+			t.Errorf("%v: Segments() = %v segments, want %v", test.name, len(segments), len(test.estimates))
+			continue
+		}
+		samples := 0
+		for i, segment := range segments {
+			if segment.Estimate != test.estimates[i] {
+				t.Errorf("%v: segment %v estimate = %v, want %v", test.name, i, segment.Estimate, test.estimates[i])
+			}
+			if want := segment.Estimate.Seconds() * 1000; !near(segment.ImpliedBufferBytes, want) {
+				t.Errorf("%v: segment %v implied buffer size = %v, want %v", test.name, i, segment.ImpliedBufferBytes, want)
+			}
+			if segment.Start != recorded[samples].At || segment.End != recorded[samples+segment.Samples-1].At {
+				t.Errorf("%v: segment %v spans %v to %v, want the times of its deltas", test.name, i, segment.Start, segment.End)
+			}
+			samples += segment.Samples
+		}
+		if samples != len(test.gaps) {
+			t.Errorf("%v: segments hold %v deltas, want %v", test.name, samples, len(test.gaps))
+		}
+	}
+}
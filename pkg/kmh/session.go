@@ -0,0 +1,381 @@
+package kmh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/grpc"
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// Session owns the lifecycle of a single measurement: opening the source,
+// running the calculator for the configured time and closing the stream.
+type Session struct {
+	config    Config
+	body      io.ReadCloser
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewSession creates a session for the measurement described by config.
+func NewSession(config Config) *Session {
+	return &Session{config: config}
+}
+
+// Connect opens the source. For the default HTTP source, this dials the
+// server and issues the GET request.
+func (s *Session) Connect(ctx context.Context) error {
+	source, err := s.source()
+	if err != nil {
+		return err
+	}
+
+	// The stream may outlive ctx's use for opening it, so the connect
+	// timeout cancels ctx only while the source is being opened.
+	ctx, s.cancel = context.WithCancel(ctx)
+	var timer *time.Timer
+	if s.config.ConnectTimeout > 0 {
+		timer = time.AfterFunc(s.config.ConnectTimeout, s.cancel)
+	}
+	body, err := source.Open(ctx)
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			body.Close()
+		}
+		return fmt.Errorf("%w after %v", ErrConnectTimeout, s.config.ConnectTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	s.body = body
+	return nil
+}
+
+// source returns the configured source or, if there is none, builds one for
+// the configured transport.
+func (s *Session) source() (Source, error) {
+	if s.config.Size == 0 {
+		return nil, ErrZeroSize
+	}
+	if s.config.Source != nil {
+		return s.config.Source, nil
+	}
+
+	switch s.config.Transport {
+	case TransportTCP:
+		return TCPSource{
+			Address: RawAddress(s.config.URL), Request: RawRequest(s.config.Size, s.config.Token, 0), Dial: s.config.Dial,
+		}, nil
+	case TransportUDP:
+		// The datagram sender requires a duration, and there is no reason
+		// for it to send past the end of the measurement.
+		return UDPSource{
+			Address: RawAddress(s.config.URL), Request: RawRequest(s.config.Size, s.config.Token, s.config.Timeout), Dial: s.config.Dial,
+		}, nil
+	}
+
+	client := s.config.Client
+	if client == nil {
+		client = NewClient(s.config.Buffer, s.config.Insecure)
+	}
+	if s.config.Transport == TransportGRPC {
+		url, err := GRPCURL(s.config.URL)
+		if err != nil {
+			return nil, err
+		}
+		return GRPCSource{Client: client, URL: url, Token: s.config.Token, Request: grpc.StreamRequest{Size: s.config.Size}}, nil
+	}
+	url, err := PeriodicURL(s.config.URL, s.config.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.config.Transport {
+	case "", TransportHTTP:
+		return HTTPSource{Client: client, URL: url, Token: s.config.Token}, nil
+	case TransportWebSocket:
+		return WebSocketSource{Client: client, URL: url, Token: s.config.Token}, nil
+	}
+	return nil, fmt.Errorf("unknown transport: %v", s.config.Transport)
+}
+
+// Describe returns the request that Connect would issue to open the source,
+// without issuing it.
+func (s *Session) Describe() (string, error) {
+	source, err := s.source()
+	if err != nil {
+		return "", err
+	}
+	switch source := source.(type) {
+	case HTTPSource:
+		return "GET " + source.URL, nil
+	case WebSocketSource:
+		return "GET " + source.URL + " (upgraded to a WebSocket)", nil
+	case GRPCSource:
+		return fmt.Sprintf("POST %v (gRPC, %v bytes per message)", source.URL, source.Request.Size), nil
+	case TCPSource:
+		return fmt.Sprintf("TCP connection to %v, then %q", source.Address, source.Request+"\n"), nil
+	case UDPSource:
+		return fmt.Sprintf("UDP datagram to %v: %q", source.Address, source.Request), nil
+	}
+	return fmt.Sprintf("%T", source), nil
+}
+
+// extensionPoll is how often a measurement extended past its timeout checks
+// whether it has recorded enough deltas.
+const extensionPoll = 100 * time.Millisecond
+
+// Measure reads from the connected source until the configured timeout
+// elapses, ctx is done or the stream ends. If fewer than the configured
+// minimum number of deltas have been recorded when the timeout elapses, the
+// measurement continues until they have been or MaxDuration elapses. The
+// stream is closed when the measurement ends so that a stalled read cannot
+// outlive it.
+func (s *Session) Measure(ctx context.Context) (Result, error) {
+	if s.body == nil {
+		return Result{}, errors.New("session is not connected")
+	}
+
+	measureCtx, measureCanceler := context.WithCancel(ctx)
+	defer measureCanceler()
+
+	options := []Option{
+		WithOnDelta(s.onDelta()),
+		WithWarmup(s.config.Warmup),
+		WithSkipFirst(s.config.SkipFirst),
+		WithSpreadCoalesced(s.config.SpreadCoalesced),
+		WithLogger(s.config.Logger),
+	}
+	if s.config.Filter != 0 {
+		options = append(options, WithFilter(s.config.Filter))
+	}
+	if s.config.AdaptiveSamples > 0 {
+		factor := s.config.AdaptiveFactor
+		if factor == 0 {
+			factor = DefaultAdaptiveFactor
+		}
+		options = append(options, WithAdaptiveFilter(s.config.AdaptiveSamples, factor))
+	}
+	kmhCalculator := NewKmhCalculator(measureCtx, nil, s.config.Size, s.body, options...)
+
+	start := time.Now()
+	extended := atomic.Bool{}
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		timer := time.NewTimer(s.config.Timeout)
+		defer timer.Stop()
+		for {
+			select {
+			case <-measureCtx.Done():
+				kmhCalculator.Close()
+				return
+			case <-timer.C:
+				recorded := len(kmhCalculator.Snapshot().Events)
+				if recorded < s.config.MinSamples && time.Since(start) < s.config.MaxDuration {
+					extended.Store(true)
+					timer.Reset(min(extensionPoll, s.config.MaxDuration-time.Since(start)))
+					continue
+				}
+				measureCanceler()
+			case <-finished:
+				return
+			}
+		}
+	}()
+	if s.config.OnInterim != nil && s.config.InterimInterval > 0 {
+		go s.reportInterim(&kmhCalculator, start, finished)
+	}
+	stalled := atomic.Bool{}
+	if s.config.ReadTimeout > 0 {
+		go s.watchReads(&kmhCalculator, &stalled, measureCanceler, finished)
+	}
+
+	_, readErr := io.ReadAll(&kmhCalculator)
+
+	snapshot := kmhCalculator.Snapshot()
+	result := Result{Deltas: kmhCalculator.Deltas(), Events: snapshot.Events, Filter: snapshot.Filter, Discarded: snapshot.Discarded, Duration: time.Since(start)}
+	if described, ok := s.body.(Described); ok {
+		result.Connection = described.Connection()
+	}
+	if counter, ok := s.body.(DatagramCounter); ok {
+		counts := counter.Datagrams()
+		result.Datagrams = &counts
+	}
+	result.Samples = len(result.Deltas)
+	result.Paced, result.Drained = Classify(snapshot.Gaps, snapshot.Filter, s.config.Size)
+	result.MaxBurst = MaxBurst(snapshot.Gaps, snapshot.Filter, s.config.Size)
+	result.Coalesced = snapshot.Coalesced
+	if readErr != nil && !errors.Is(readErr, ErrCancelled) {
+		result.Errors = append(result.Errors, readErr)
+	}
+	if stalled.Load() {
+		result.Errors = append(result.Errors, fmt.Errorf("%w (%v)", ErrReadTimeout, s.config.ReadTimeout))
+	}
+	result.Extended = extended.Load()
+	result.Throughput = Throughput(snapshot.PerSecond, result.Duration)
+	if result.Samples == 0 {
+		result.Errors = append(result.Errors, ErrShortSample)
+	} else if result.Samples < s.config.MinSamples {
+		result.Errors = append(result.Errors, fmt.Errorf("%w: %v of the %v required", ErrShortSample, result.Samples, s.config.MinSamples))
+	}
+
+	if result.Samples > 1 {
+		result.StdDev = time.Duration(stats.StdDev(result.Deltas))
+		result.Jitter = time.Duration(stats.Jitter(result.Deltas))
+		result.CV = stats.CV(result.Deltas)
+		result.HighVariation = result.CV > HighVariationCV
+	}
+	if result.Samples > 2 && result.StdDev > 0 {
+		result.Skewness = stats.Skewness(result.Deltas)
+		result.Kurtosis = stats.Kurtosis(result.Deltas)
+	}
+
+	percentiles := s.config.Percentiles
+	if percentiles == nil {
+		percentiles = DefaultPercentiles
+	}
+	if result.Samples > 0 {
+		result.Mean = time.Duration(stats.Mean(result.Deltas))
+		result.HarmonicMean = time.Duration(stats.HarmonicMean(result.Deltas))
+		for _, p := range percentiles {
+			result.Percentiles = append(result.Percentiles, DeltaPercentile{P: p, Delta: time.Duration(stats.Percentile(result.Deltas, p))})
+		}
+	}
+
+	statistic := s.config.Statistic
+	if statistic == nil {
+		statistic = &Mean{}
+	}
+	estimated := RejectOutliers(result.Deltas, s.config.OutlierThreshold)
+	result.Rejected = len(result.Deltas) - len(estimated)
+	for _, delta := range estimated {
+		statistic.Add(time.Duration(delta))
+	}
+	result.Estimate = statistic.Result()
+	if uncertain, ok := statistic.(Uncertain); ok && len(estimated) > 0 {
+		result.Uncertainty = uncertain.Uncertainty()
+	}
+	if result.Duration > 0 {
+		result.Goodput = float64(snapshot.Bytes) / result.Duration.Seconds()
+	}
+	result.ImpliedBufferBytes = result.Estimate.Seconds() * float64(s.config.Size)
+	if result.MaxBurst > 0 && result.Goodput > 0 {
+		result.BufferDelay = QueueDelay(result.MaxBurst, s.config.Size, result.Goodput)
+		result.Grade = Grade(result.BufferDelay)
+	}
+
+	if len(estimated) > 0 {
+		sizes := make([]float64, len(estimated))
+		for i, delta := range estimated {
+			sizes[i] = time.Duration(delta).Seconds() * float64(s.config.Size)
+		}
+		result.ImpliedBufferSizes = ImpliedRange{
+			Min:    stats.Min(sizes),
+			Q1:     stats.Percentile(sizes, 25),
+			Median: stats.Median(sizes),
+			Q3:     stats.Percentile(sizes, 75),
+			Max:    stats.Max(sizes),
+		}
+	}
+
+	result.LowConfidence = len(estimated) < LowConfidenceSamples
+	if len(estimated) > 1 {
+		low, high := stats.MeanInterval95(estimated)
+		result.Interval = [2]time.Duration{time.Duration(low), time.Duration(high)}
+		result.ImpliedBufferInterval = [2]float64{
+			result.Interval[0].Seconds() * float64(s.config.Size),
+			result.Interval[1].Seconds() * float64(s.config.Size),
+		}
+	}
+
+	result.Regression = Regress(result.Events, s.config.Size)
+	if s.config.ChangePointThreshold > 0 {
+		result.Segments = Segments(result.Events, s.config.ChangePointThreshold, s.config.Size)
+	}
+
+	return result, nil
+}
+
+// reportInterim passes the progress of the measurement that began at start
+// to the configured callback every InterimInterval until finished is closed.
+func (s *Session) reportInterim(kmhCalculator *KmhCalculator, start time.Time, finished <-chan struct{}) {
+	ticker := time.NewTicker(s.config.InterimInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			snapshot := kmhCalculator.Snapshot()
+			interim := Interim{Elapsed: time.Since(start), Samples: len(snapshot.Events), Bytes: snapshot.Bytes}
+			mean := Mean{}
+			for _, event := range snapshot.Events {
+				mean.Add(event.Gap)
+			}
+			interim.Estimate = mean.Result()
+			s.config.OnInterim(interim)
+		case <-finished:
+			return
+		}
+	}
+}
+
+// watchReads ends the measurement with cancel, and marks it stalled, once no
+// data has arrived for ReadTimeout. It returns when finished is closed.
+func (s *Session) watchReads(kmhCalculator *KmhCalculator, stalled *atomic.Bool, cancel context.CancelFunc, finished <-chan struct{}) {
+	ticker := time.NewTicker(max(s.config.ReadTimeout/10, time.Millisecond))
+	defer ticker.Stop()
+	received, since := uint64(0), time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			if bytes := kmhCalculator.Snapshot().Bytes; bytes != received {
+				received, since = bytes, time.Now()
+			} else if time.Since(since) >= s.config.ReadTimeout {
+				stalled.Store(true)
+				cancel()
+				return
+			}
+		case <-finished:
+			return
+		}
+	}
+}
+
+// onDelta returns the function to call with each recorded delta, which
+// passes it on to the configured callbacks.
+func (s *Session) onDelta() func(d time.Duration, ts time.Time) {
+	if s.config.OnEstimate == nil {
+		return s.config.OnDelta
+	}
+	alpha := s.config.LiveAlpha
+	if alpha == 0 {
+		alpha = DefaultEWMAAlpha
+	}
+	live := &EWMA{Alpha: alpha}
+	return func(d time.Duration, ts time.Time) {
+		if s.config.OnDelta != nil {
+			s.config.OnDelta(d, ts)
+		}
+		live.Add(d)
+		s.config.OnEstimate(live.Result(), ts)
+	}
+}
+
+// Close closes the stream. It is safe to call more than once.
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		if s.cancel != nil {
+			s.cancel()
+		}
+		if s.body != nil {
+			s.closeErr = s.body.Close()
+		}
+	})
+	return s.closeErr
+}
@@ -0,0 +1,83 @@
+package kmh
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// synthetic returns the configuration of a short measurement of a
+// SyntheticSource sending 100 bytes every 50ms.
+func synthetic() Config {
+	return Config{
+		Size:    100,
+		Timeout: 600 * time.Millisecond,
+		Filter:  20 * time.Millisecond,
+		Source:  SyntheticSource{Size: 100, Interval: 50 * time.Millisecond},
+	}
+}
+
+func TestRun(t *testing.T) {
+	result, err := Run(context.Background(), synthetic())
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if result.Samples < 5 {
+		t.Errorf("Samples = %v, want at least 5", result.Samples)
+	}
+	if result.Estimate < 40*time.Millisecond || result.Estimate > 100*time.Millisecond {
+		t.Errorf("Estimate = %v, want about 50ms", result.Estimate)
+	}
+	if want := result.Estimate.Seconds() * 100; result.ImpliedBufferBytes != want {
+		t.Errorf("ImpliedBufferBytes = %v, want the estimate times the chunk size, %v", result.ImpliedBufferBytes, want)
+	}
+	if result.Goodput <= 0 {
+		t.Errorf("Goodput = %v, want more than 0", result.Goodput)
+	}
+}
+
+func TestRunZeroSize(t *testing.T) {
+	config := synthetic()
+	config.Size = 0
+	if _, err := Run(context.Background(), config); !errors.Is(err, ErrZeroSize) {
+		t.Errorf("Run() = %v, want %v", err, ErrZeroSize)
+	}
+}
+
+func TestSession(t *testing.T) {
+	session := NewSession(synthetic())
+	if err := session.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() = %v", err)
+	}
+	result, err := session.Measure(context.Background())
+	if err != nil {
+		t.Fatalf("Measure() = %v", err)
+	}
+	if result.Samples == 0 {
+		t.Errorf("Samples = 0, want deltas to be recorded")
+	}
+	if err := session.Close(); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if err := session.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
+
+func TestSessionCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	session := NewSession(synthetic())
+	defer session.Close()
+	if err := session.Connect(ctx); err != nil {
+		t.Fatalf("Connect() = %v", err)
+	}
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := session.Measure(ctx); err != nil {
+		t.Fatalf("Measure() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Measure() returned after %v, want it to stop when cancelled", elapsed)
+	}
+}
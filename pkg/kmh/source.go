@@ -0,0 +1,156 @@
+package kmh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source is the origin of a periodic stream.
+type Source interface {
+	// Open starts the stream and returns its body. The caller closes the
+	// body when the measurement is complete.
+	Open(ctx context.Context) (io.ReadCloser, error)
+}
+
+// Transports that a Session can build a Source for.
+const (
+	TransportHTTP      = "http"
+	TransportWebSocket = "websocket"
+	TransportTCP       = "tcp"
+	TransportUDP       = "udp"
+	TransportGRPC      = "grpc"
+)
+
+// HTTPSource streams the body of a GET request to a periodic endpoint. If
+// Token is not empty, it is presented as a bearer token.
+type HTTPSource struct {
+	Client *http.Client
+	URL    string
+	Token  string
+}
+
+func (s HTTPSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	connection := Connection{}
+	response, err := s.Client.Do(traceAddresses(request, &connection))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected response status: %v", response.Status)
+	}
+	connection.Protocol = response.Proto
+	connection.setTLS(response.TLS)
+	connection.Proxy = proxyFor(s.Client, request)
+	return describedBody{ReadCloser: response.Body, connection: connection}, nil
+}
+
+// TCPSource streams everything sent by a periodic sender over a plain TCP
+// connection. If Request is not empty, it is sent, followed by a newline,
+// once connected. If Dial is not nil, it makes the connection; see
+// ClientConfig.Dialer.
+type TCPSource struct {
+	Address string
+	Request string
+	Dial    func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func (s TCPSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	dial := s.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	start := time.Now()
+	conn, err := dial(ctx, "tcp", s.Address)
+	if err != nil {
+		return nil, err
+	}
+	rtt := time.Since(start)
+	if s.Request != "" {
+		if _, err := fmt.Fprintf(conn, "%v\n", s.Request); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	connection := Connection{Protocol: "tcp", RTT: rtt}
+	connection.setAddresses(conn)
+	return describedBody{ReadCloser: conn, connection: connection}, nil
+}
+
+// RawRequest returns the request that asks the raw TCP or UDP sender of
+// package server for chunks of size bytes, presenting token if it is not
+// empty. A duration of zero leaves the length of the test to the server.
+func RawRequest(size uint64, token string, duration time.Duration) string {
+	query := url.Values{}
+	query.Set("size", strconv.FormatUint(size, 10))
+	if token != "" {
+		query.Set("token", token)
+	}
+	if duration != 0 {
+		query.Set("duration", duration.String())
+	}
+	return query.Encode()
+}
+
+// RawAddress returns the host and port of target, which may be preceded by a
+// scheme, such as tcp:// or udp://, and followed by a path, which is ignored.
+func RawAddress(target string) string {
+	if _, rest, found := strings.Cut(target, "://"); found {
+		target = rest
+	}
+	address, _, _ := strings.Cut(target, "/")
+	return address
+}
+
+// FileSource replays a stream from a file, such as a named pipe or a
+// capture of a periodic stream.
+type FileSource struct {
+	Path string
+}
+
+func (s FileSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return os.Open(s.Path)
+}
+
+// SyntheticSource generates a chunk of Size bytes every Interval without
+// touching the network.
+type SyntheticSource struct {
+	Size     uint64
+	Interval time.Duration
+}
+
+func (s SyntheticSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		chunk := make([]byte, s.Size)
+		for {
+			select {
+			case <-ctx.Done():
+				writer.CloseWithError(ctx.Err())
+				return
+			case <-ticker.C:
+				if _, err := writer.Write(chunk); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return reader, nil
+}
@@ -0,0 +1,239 @@
+package kmh
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// Statistic aggregates recorded deltas into a single estimate.
+type Statistic interface {
+	// Add records a delta.
+	Add(delta time.Duration)
+	// Result returns the estimate of the deltas recorded so far.
+	Result() time.Duration
+}
+
+// Uncertain is implemented by statistics that can tell how uncertain their
+// estimate is.
+type Uncertain interface {
+	// Uncertainty returns the standard deviation of the estimate.
+	Uncertainty() time.Duration
+}
+
+// NewStatistic returns the Statistic with the given name: mean, harmonic,
+// median, ewma, welford, kalman or a percentile such as p90.
+func NewStatistic(name string) (Statistic, error) {
+	if value, found := strings.CutPrefix(name, "p"); found {
+		p, err := strconv.ParseFloat(value, 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile: %v", name)
+		}
+		return &Percentile{P: p}, nil
+	}
+	switch name {
+	case "mean":
+		return &Mean{}, nil
+	case "harmonic":
+		return &HarmonicMean{}, nil
+	case "median":
+		return &Median{}, nil
+	case "ewma":
+		return &EWMA{Alpha: DefaultEWMAAlpha}, nil
+	case "welford":
+		return &Welford{}, nil
+	case "kalman":
+		return &Kalman{ProcessNoise: DefaultKalmanProcessNoise, MeasurementNoise: DefaultKalmanMeasurementNoise}, nil
+	}
+	return nil, fmt.Errorf("unknown statistic: %v", name)
+}
+
+// Mean is the arithmetic mean of the deltas.
+type Mean struct {
+	total time.Duration
+	count int
+}
+
+func (m *Mean) Add(delta time.Duration) {
+	m.total += delta
+	m.count++
+}
+
+func (m *Mean) Result() time.Duration {
+	if m.count == 0 {
+		return 0
+	}
+	return m.total / time.Duration(m.count)
+}
+
+// HarmonicMean is the harmonic mean of the deltas: the reciprocal of the mean
+// of their reciprocals, the rates at which chunks arrived. Long deltas weigh
+// less than in the arithmetic mean.
+type HarmonicMean struct {
+	reciprocals float64
+	count       int
+}
+
+func (h *HarmonicMean) Add(delta time.Duration) {
+	if delta > 0 {
+		h.reciprocals += 1 / float64(delta)
+		h.count++
+	}
+}
+
+func (h *HarmonicMean) Result() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return time.Duration(float64(h.count) / h.reciprocals)
+}
+
+// TrimmedMean is the arithmetic mean of the deltas after dropping the
+// smallest and largest Percent of them, for Percent between 0 and 50.
+type TrimmedMean struct {
+	Percent float64
+	deltas  []time.Duration
+}
+
+func (t *TrimmedMean) Add(delta time.Duration) {
+	t.deltas = append(t.deltas, delta)
+}
+
+func (t *TrimmedMean) Result() time.Duration {
+	if len(t.deltas) == 0 {
+		return 0
+	}
+	return time.Duration(stats.TrimmedMean(t.deltas, t.Percent))
+}
+
+// Median is the middle value of the deltas.
+type Median struct {
+	deltas []time.Duration
+}
+
+func (m *Median) Add(delta time.Duration) {
+	m.deltas = append(m.deltas, delta)
+}
+
+func (m *Median) Result() time.Duration {
+	if len(m.deltas) == 0 {
+		return 0
+	}
+	return time.Duration(stats.Median(m.deltas))
+}
+
+// Percentile is the Pth percentile of the deltas, for P between 0 and 100.
+type Percentile struct {
+	P      float64
+	deltas []time.Duration
+}
+
+func (p *Percentile) Add(delta time.Duration) {
+	p.deltas = append(p.deltas, delta)
+}
+
+func (p *Percentile) Result() time.Duration {
+	if len(p.deltas) == 0 {
+		return 0
+	}
+	return time.Duration(stats.Percentile(p.deltas, p.P))
+}
+
+// DefaultEWMAAlpha is the weight given to each new delta by the EWMA
+// returned from NewStatistic.
+const DefaultEWMAAlpha = 0.125
+
+// EWMA is an exponentially weighted moving average of the deltas. Alpha is
+// the weight, between 0 and 1, given to each new delta.
+type EWMA struct {
+	Alpha   float64
+	average float64
+	seeded  bool
+}
+
+func (e *EWMA) Add(delta time.Duration) {
+	if !e.seeded {
+		e.average = float64(delta)
+		e.seeded = true
+		return
+	}
+	e.average = e.Alpha*float64(delta) + (1-e.Alpha)*e.average
+}
+
+func (e *EWMA) Result() time.Duration {
+	return time.Duration(e.average)
+}
+
+// Welford computes the mean and variance of the deltas in a single,
+// numerically stable pass.
+type Welford struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+func (w *Welford) Add(delta time.Duration) {
+	w.count++
+	difference := float64(delta) - w.mean
+	w.mean += difference / float64(w.count)
+	w.m2 += difference * (float64(delta) - w.mean)
+}
+
+// Result returns the mean of the deltas.
+func (w *Welford) Result() time.Duration {
+	return time.Duration(w.mean)
+}
+
+// StdDev returns the sample standard deviation of the deltas.
+func (w *Welford) StdDev() time.Duration {
+	if w.count < 2 {
+		return 0
+	}
+	return time.Duration(math.Sqrt(w.m2 / float64(w.count-1)))
+}
+
+// DefaultKalmanProcessNoise and DefaultKalmanMeasurementNoise configure the
+// Kalman filter returned from NewStatistic.
+const (
+	DefaultKalmanProcessNoise     = 10 * time.Millisecond
+	DefaultKalmanMeasurementNoise = 100 * time.Millisecond
+)
+
+// Kalman tracks the deltas with a one-dimensional Kalman filter that models
+// the true delta as a random walk. ProcessNoise is the standard deviation of
+// the true delta's change between deltas and MeasurementNoise that of a
+// delta around the true one. Unlike an average, it follows the deltas when
+// conditions change, and it tracks the uncertainty of its estimate.
+type Kalman struct {
+	ProcessNoise     time.Duration
+	MeasurementNoise time.Duration
+	estimate         float64
+	variance         float64
+	seeded           bool
+}
+
+func (k *Kalman) Add(delta time.Duration) {
+	measurementVariance := float64(k.MeasurementNoise) * float64(k.MeasurementNoise)
+	if !k.seeded {
+		k.estimate = float64(delta)
+		k.variance = measurementVariance
+		k.seeded = true
+		return
+	}
+	k.variance += float64(k.ProcessNoise) * float64(k.ProcessNoise)
+	gain := k.variance / (k.variance + measurementVariance)
+	k.estimate += gain * (float64(delta) - k.estimate)
+	k.variance *= 1 - gain
+}
+
+func (k *Kalman) Result() time.Duration {
+	return time.Duration(k.estimate)
+}
+
+func (k *Kalman) Uncertainty() time.Duration {
+	return time.Duration(math.Sqrt(k.variance))
+}
@@ -0,0 +1,60 @@
+package kmh
+
+import (
+	"fmt"
+	"time"
+)
+
+// ThroughputInterval is the goodput achieved over one interval of a
+// measurement, measured from its start.
+type ThroughputInterval struct {
+	Start time.Duration
+	End   time.Duration
+	// Bytes is the number of bytes received during the interval.
+	Bytes uint64
+}
+
+type jsonThroughputInterval struct {
+	Start int64   `json:"start_ns"`
+	End   int64   `json:"end_ns"`
+	Bytes uint64  `json:"bytes"`
+	Rate  float64 `json:"bits_per_second"`
+}
+
+// Rate returns the goodput over the interval, in bits per second.
+func (i ThroughputInterval) Rate() float64 {
+	if i.End <= i.Start {
+		return 0
+	}
+	return float64(i.Bytes) * 8 / (i.End - i.Start).Seconds()
+}
+
+// String formats the interval like an iperf interval line.
+func (i ThroughputInterval) String() string {
+	return fmt.Sprintf("[%5.1f-%5.1f sec] %10.2f KiB %10.2f Kbit/s", i.Start.Seconds(), i.End.Seconds(), float64(i.Bytes)/1024, i.Rate()/1000)
+}
+
+// Throughput divides the bytes received in each second of a measurement that
+// lasted duration into intervals. The last interval ends with the
+// measurement; if it would be shorter than half a second, whose rate would be
+// noisy, it is merged into the interval before it.
+func Throughput(perSecond []uint64, duration time.Duration) []ThroughputInterval {
+	seconds := int((duration + time.Second - 1) / time.Second)
+	intervals := make([]ThroughputInterval, 0, seconds)
+	for second := 0; second < seconds; second++ {
+		interval := ThroughputInterval{
+			Start: time.Duration(second) * time.Second,
+			End:   min(time.Duration(second+1)*time.Second, duration),
+		}
+		if second < len(perSecond) {
+			interval.Bytes = perSecond[second]
+		}
+		if last := len(intervals) - 1; last >= 0 && interval.End-interval.Start < time.Second/2 {
+			intervals[last].End = interval.End
+			intervals[last].Bytes += interval.Bytes
+			continue
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals
+}
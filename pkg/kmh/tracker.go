@@ -0,0 +1,205 @@
+package kmh
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/stats"
+)
+
+// DeltaEvent is a recorded gap between two chunks.
+type DeltaEvent struct {
+	// At is when the chunk completing the gap arrived.
+	At time.Time `json:"at"`
+	// Gap is the time since the previous chunk arrived.
+	Gap time.Duration `json:"gap_ns"`
+	// Bytes is the total number of bytes that had passed through the stream
+	// when the chunk completing the gap arrived.
+	Bytes uint64 `json:"bytes"`
+}
+
+// Snapshot is a consistent view of a measurement that may still be running.
+type Snapshot struct {
+	// Events are the gaps recorded so far.
+	Events []DeltaEvent
+	// Bytes is the number of bytes that have passed through the stream.
+	Bytes uint64
+	// Filter is the minimum gap being recorded as a delta. An adaptive
+	// filter is zero until it has been learned.
+	Filter time.Duration
+	// Discarded is the number of deltas discarded during the warm-up.
+	Discarded int
+	// Gaps are the gaps before every chunk, whether or not they were
+	// recorded as deltas.
+	Gaps []time.Duration
+	// PerSecond are the bytes that passed through the stream in each second
+	// since the measurement started.
+	PerSecond []uint64
+	// Coalesced is the number of chunks that completed in the same read as
+	// an earlier chunk, as happens when segmentation offload coalesces
+	// their packets.
+	Coalesced int
+}
+
+// tracker counts the bytes passing through a stream and records the gap
+// between consecutive size-byte boundary crossings.
+type tracker struct {
+	size    uint64
+	current uint64
+	total   uint64
+	last    time.Time
+	filter  time.Duration
+	events  []DeltaEvent
+	gaps    []time.Duration
+	seconds []uint64
+	lock    *sync.Mutex
+	logger  *slog.Logger
+	now     func() time.Time
+	onDelta func(d time.Duration, ts time.Time)
+
+	// An adaptive filter is learned from the first learn gaps, which are
+	// not recorded, as factor times their median.
+	learn   int
+	factor  float64
+	learned []time.Duration
+
+	// Deltas completed within warmup of start, and the first skip deltas,
+	// are discarded.
+	start     time.Time
+	warmup    time.Duration
+	skip      int
+	discarded int
+
+	// Chunks that complete in the same read are counted as coalesced and,
+	// if spread, are given arrival times spread over the time since the
+	// read before in proportion to their bytes.
+	lastRead  time.Time
+	spread    bool
+	coalesced int
+}
+
+// DefaultFilter is the minimum gap between chunks recorded as a delta unless
+// another is chosen with WithFilter.
+const DefaultFilter = 1 * time.Second
+
+func newTracker(size uint64, options []Option) tracker {
+	if size == 0 {
+		panic("kmh: " + ErrZeroSize.Error())
+	}
+	t := tracker{size: size, filter: DefaultFilter, now: time.Now, lock: &sync.Mutex{}}
+	for _, option := range options {
+		option(&t)
+	}
+	t.last = t.now()
+	t.start = t.last
+	t.lastRead = t.last
+	return t
+}
+
+// account records that n more bytes passed through the stream.
+func (t *tracker) account(n int) {
+	t.lock.Lock()
+	recorded := len(t.events)
+
+	t.log(LevelTrace, "read", "bytes", n, "current", t.current)
+
+	packetized := uint64(n)
+	consumed := t.total
+	readStart := consumed
+	t.total += packetized
+	readAt := t.now()
+	previousRead := t.lastRead
+	t.lastRead = readAt
+	crossed := 0
+	if second := int(readAt.Sub(t.start) / time.Second); second >= 0 {
+		for len(t.seconds) <= second {
+			t.seconds = append(t.seconds, 0)
+		}
+		t.seconds[second] += packetized
+	}
+	for t.current+packetized >= t.size {
+		packetized -= (t.size - t.current)
+		consumed += (t.size - t.current)
+		t.current = 0
+		now := t.now()
+		if crossed > 0 {
+			t.coalesced++
+		}
+		if t.spread && (crossed > 0 || packetized >= t.size) {
+			fraction := float64(consumed-readStart) / float64(n)
+			now = previousRead.Add(time.Duration(fraction * float64(readAt.Sub(previousRead))))
+		}
+		crossed++
+		recentDelta := now.Sub(t.last)
+		t.last = now
+		t.gaps = append(t.gaps, recentDelta)
+
+		if len(t.learned) < t.learn {
+			t.learned = append(t.learned, recentDelta)
+			if len(t.learned) == t.learn {
+				t.filter = time.Duration(t.factor * stats.Median(t.learned))
+				t.log(slog.LevelDebug, "learned the filter", "filter", t.filter)
+			}
+			t.log(slog.LevelDebug, "learning the filter from a gap", "gap", recentDelta)
+		} else if recentDelta > t.filter && (now.Sub(t.start) < t.warmup || t.discarded < t.skip) {
+			t.log(slog.LevelDebug, "discarding a delta during the warm-up", "gap", recentDelta)
+			t.discarded++
+		} else if recentDelta > t.filter {
+			t.log(slog.LevelDebug, "recording a delta", "gap", recentDelta, "filter", t.filter)
+			t.events = append(t.events, DeltaEvent{At: now, Gap: recentDelta, Bytes: consumed})
+		} else {
+			t.log(slog.LevelDebug, "skipping a gap shorter than the filter", "gap", recentDelta, "filter", t.filter)
+		}
+		t.log(LevelTrace, "completed a chunk", "remaining", packetized)
+	}
+	t.current += packetized
+	added := append([]DeltaEvent(nil), t.events[recorded:]...)
+	t.lock.Unlock()
+
+	// Callbacks run without the lock held so that they may take a snapshot.
+	if t.onDelta != nil {
+		for _, event := range added {
+			t.onDelta(event.Gap, event.At)
+		}
+	}
+}
+
+// LevelTrace is the level at which every read is logged, below the
+// slog.LevelDebug at which the filter's decisions are.
+const LevelTrace = slog.LevelDebug - 4
+
+// log logs a message at level, if there is a logger.
+func (t *tracker) log(level slog.Level, msg string, args ...any) {
+	if t.logger != nil {
+		t.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+func (t *tracker) snapshot() Snapshot {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	filter := t.filter
+	if len(t.learned) < t.learn {
+		filter = 0
+	}
+	return Snapshot{
+		Events:    append([]DeltaEvent(nil), t.events...),
+		Bytes:     t.total,
+		Filter:    filter,
+		Discarded: t.discarded,
+		Gaps:      append([]time.Duration(nil), t.gaps...),
+		PerSecond: append([]uint64(nil), t.seconds...),
+		Coalesced: t.coalesced,
+	}
+}
+
+func (t *tracker) deltas() []int64 {
+	events := t.snapshot().Events
+	deltas := make([]int64, len(events))
+	for i, event := range events {
+		deltas[i] = event.Gap.Nanoseconds()
+	}
+	return deltas
+}
@@ -0,0 +1,133 @@
+package kmh
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// read is a read of bytes that completes after a wait.
+type read struct {
+	after time.Duration
+	bytes int
+}
+
+// track accounts reads of chunks of size bytes, on a clock that advances only
+// by their waits, and returns the tracker's snapshot.
+func track(size uint64, reads []read, options ...Option) Snapshot {
+	now := time.Unix(0, 0)
+	t := newTracker(size, append([]Option{WithClock(func() time.Time { return now })}, options...))
+	for _, r := range reads {
+		now = now.Add(r.after)
+		t.account(r.bytes)
+	}
+	return t.snapshot()
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name      string
+		reads     []read
+		options   []Option
+		deltas    []time.Duration
+		filter    time.Duration
+		discarded int
+		coalesced int
+	}{
+		{
+			name:    "filter",
+			reads:   []read{{time.Second, 100}, {200 * time.Millisecond, 100}, {2 * time.Second, 100}},
+			options: []Option{WithFilter(500 * time.Millisecond)},
+			deltas:  []time.Duration{time.Second, 2 * time.Second},
+			filter:  500 * time.Millisecond,
+		},
+		{
+			name:    "partial chunks",
+			reads:   []read{{time.Second, 60}, {time.Second, 60}, {time.Second, 80}},
+			options: []Option{WithFilter(500 * time.Millisecond)},
+			deltas:  []time.Duration{2 * time.Second, time.Second},
+			filter:  500 * time.Millisecond,
+		},
+		{
+			name:      "warmup",
+			reads:     []read{{time.Second, 100}, {time.Second, 100}, {time.Second, 100}},
+			options:   []Option{WithFilter(500 * time.Millisecond), WithWarmup(1500 * time.Millisecond)},
+			deltas:    []time.Duration{time.Second, time.Second},
+			filter:    500 * time.Millisecond,
+			discarded: 1,
+		},
+		{
+			name:      "skip first",
+			reads:     []read{{time.Second, 100}, {time.Second, 100}, {time.Second, 100}},
+			options:   []Option{WithFilter(500 * time.Millisecond), WithSkipFirst(2)},
+			deltas:    []time.Duration{time.Second},
+			filter:    500 * time.Millisecond,
+			discarded: 2,
+		},
+		{
+			name: "adaptive threshold",
+			reads: []read{
+				{100 * time.Millisecond, 100}, {100 * time.Millisecond, 100}, {100 * time.Millisecond, 100},
+				{150 * time.Millisecond, 100}, {300 * time.Millisecond, 100},
+			},
+			options: []Option{WithAdaptiveFilter(3, 2)},
+			deltas:  []time.Duration{300 * time.Millisecond},
+			filter:  200 * time.Millisecond,
+		},
+		{
+			name:    "adaptive threshold not yet learned",
+			reads:   []read{{time.Second, 100}, {time.Second, 100}},
+			options: []Option{WithAdaptiveFilter(3, 2)},
+			filter:  0,
+		},
+		{
+			name:      "coalesced",
+			reads:     []read{{time.Second, 300}},
+			options:   []Option{WithFilter(500 * time.Millisecond)},
+			deltas:    []time.Duration{time.Second},
+			filter:    500 * time.Millisecond,
+			coalesced: 2,
+		},
+		{
+			name:      "coalesced and spread",
+			reads:     []read{{time.Second, 100}, {time.Second, 200}},
+			options:   []Option{WithFilter(100 * time.Millisecond), WithSpreadCoalesced(true)},
+			deltas:    []time.Duration{time.Second, 500 * time.Millisecond, 500 * time.Millisecond},
+			filter:    100 * time.Millisecond,
+			coalesced: 1,
+		},
+	}
+	for _, test := range tests {
+		snapshot := track(100, test.reads, test.options...)
+		var deltas []time.Duration
+		for _, event := range snapshot.Events {
+			deltas = append(deltas, event.Gap)
+		}
+		if !reflect.DeepEqual(deltas, test.deltas) {
+			t.Errorf("%v: deltas = %v, want %v", test.name, deltas, test.deltas)
+		}
+		if snapshot.Filter != test.filter {
+			t.Errorf("%v: filter = %v, want %v", test.name, snapshot.Filter, test.filter)
+		}
+		if snapshot.Discarded != test.discarded {
+			t.Errorf("%v: discarded = %v, want %v", test.name, snapshot.Discarded, test.discarded)
+		}
+		if snapshot.Coalesced != test.coalesced {
+			t.Errorf("%v: coalesced = %v, want %v", test.name, snapshot.Coalesced, test.coalesced)
+		}
+	}
+}
+
+func TestTrackerBytes(t *testing.T) {
+	snapshot := track(100, []read{{time.Second, 150}, {time.Second, 150}}, WithFilter(500*time.Millisecond))
+	if snapshot.Bytes != 300 {
+		t.Errorf("Bytes = %v, want 300", snapshot.Bytes)
+	}
+	want := []uint64{0, 150, 150}
+	if !reflect.DeepEqual(snapshot.PerSecond, want) {
+		t.Errorf("PerSecond = %v, want %v", snapshot.PerSecond, want)
+	}
+	if len(snapshot.Events) != 2 || snapshot.Events[0].Bytes != 100 || snapshot.Events[1].Bytes != 200 {
+		t.Errorf("Events = %v, want chunks completing at 100 and 200 bytes", snapshot.Events)
+	}
+}
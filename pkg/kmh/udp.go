@@ -0,0 +1,202 @@
+package kmh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/datagram"
+)
+
+// DatagramStats counts the datagrams of a stream carried over UDP.
+type DatagramStats struct {
+	// Received is the number of distinct datagrams received.
+	Received uint64 `json:"received"`
+	// Lost is the number of datagrams, up to the last one received, that
+	// never arrived.
+	Lost uint64 `json:"lost"`
+	// Reordered is the number of datagrams that arrived after one sent
+	// later.
+	Reordered uint64 `json:"reordered"`
+	// Duplicated is the number of datagrams that arrived more than once.
+	Duplicated uint64 `json:"duplicated"`
+}
+
+// DatagramCounter is implemented by streams that are carried over UDP.
+// Sources may return such a stream from Open so that its counts are included
+// in the Result.
+type DatagramCounter interface {
+	Datagrams() DatagramStats
+}
+
+// UDPSource streams the datagrams sent by the UDP periodic sender of package
+// server after sending it Request and echoing the cookie with which it
+// replies. Each datagram, header included, is read as Size bytes of the
+// stream, so that gaps are recorded per datagram. If Dial is not nil, it
+// makes the connection; see ClientConfig.Dialer.
+type UDPSource struct {
+	Address string
+	Request string
+	Dial    func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// The request is sent up to handshakeAttempts times, each time waiting up to
+// handshakeTimeout for a reply, as either it or the reply may be lost. The
+// echo of the cookie is resent every handshakeTimeout until the first data
+// datagram arrives, which may take an interval, for up to echoTimeout: the
+// longest interval the server of package server allows by default.
+const (
+	handshakeAttempts = 5
+	handshakeTimeout  = time.Second
+	echoTimeout       = time.Minute
+)
+
+// ErrHandshake is returned when the UDP sender does not start the test.
+var ErrHandshake = errors.New("UDP handshake failed")
+
+func (s UDPSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	dial := s.Dial
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	conn, err := dial(ctx, "udp", s.Address)
+	if err != nil {
+		return nil, err
+	}
+	reader := &datagramReader{conn: conn, buffer: make([]byte, datagram.MaxSize), seen: map[uint64]bool{}}
+	if err := reader.handshake(ctx, s.Request); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// datagramReader reads the payloads of datagrams as a stream and counts them
+// by their sequence numbers.
+type datagramReader struct {
+	conn    net.Conn
+	buffer  []byte
+	pending []byte
+
+	lock    sync.Mutex
+	seen    map[uint64]bool
+	highest uint64
+	counts  DatagramStats
+}
+
+// handshake sends request, echoes the cookie with which the server replies
+// and waits for the first data datagram, which is left to Read.
+func (r *datagramReader) handshake(ctx context.Context, request string) error {
+	defer r.conn.SetReadDeadline(time.Time{})
+	sent, echoed := 0, time.Time{}
+	message := datagram.PadRequest(request)
+	for {
+		if echoed.IsZero() && sent == handshakeAttempts {
+			return fmt.Errorf("%w: the server did not reply after %v requests", ErrHandshake, handshakeAttempts)
+		}
+		if !echoed.IsZero() && time.Since(echoed) >= echoTimeout {
+			return fmt.Errorf("%w: no data arrived within %v of echoing the cookie", ErrHandshake, echoTimeout)
+		}
+		if _, err := r.conn.Write([]byte(message)); err != nil {
+			return err
+		}
+		sent++
+
+		deadline := time.Now().Add(handshakeTimeout)
+		if done, ok := ctx.Deadline(); ok && done.Before(deadline) {
+			deadline = done
+		}
+		r.conn.SetReadDeadline(deadline)
+		n, err := r.conn.Read(r.buffer)
+		if errors.Is(err, os.ErrDeadlineExceeded) && ctx.Err() == nil {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if header, err := datagram.Parse(r.buffer[:n]); err == nil && header.Sequence == datagram.CookieSequence {
+			// The reply to a repeated request may carry a newer cookie.
+			message = datagram.PadRequest(datagram.CookieRequest(request, string(r.buffer[datagram.HeaderSize:n])))
+			if echoed.IsZero() {
+				echoed = time.Now()
+			}
+			continue
+		}
+		// A refusal, or the first data datagram.
+		return r.receive(r.buffer[:n])
+	}
+}
+
+func (r *datagramReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		n, err := r.conn.Read(r.buffer)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.receive(r.buffer[:n]); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// receive queues the payload of the datagram b to be read, unless it is too
+// short, a copy or a late cookie, and returns the server's refusal if it is
+// one.
+func (r *datagramReader) receive(b []byte) error {
+	header, err := datagram.Parse(b)
+	if errors.Is(err, datagram.ErrShort) || header.Sequence == datagram.CookieSequence {
+		return nil
+	}
+	if header.Sequence == 0 {
+		return fmt.Errorf("server refused the test: %s", b[datagram.HeaderSize:])
+	}
+	if r.count(header.Sequence) {
+		r.pending = b
+	}
+	return nil
+}
+
+// count records the arrival of the datagram numbered sequence and reports
+// whether it is the first copy of that datagram.
+func (r *datagramReader) count(sequence uint64) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.seen[sequence] {
+		r.counts.Duplicated++
+		return false
+	}
+	r.seen[sequence] = true
+	r.counts.Received++
+	if sequence < r.highest {
+		r.counts.Reordered++
+	} else {
+		r.highest = sequence
+	}
+	return true
+}
+
+func (r *datagramReader) Datagrams() DatagramStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	counts := r.counts
+	counts.Lost = r.highest - counts.Received
+	return counts
+}
+
+func (r *datagramReader) Connection() Connection {
+	connection := Connection{Protocol: "udp"}
+	connection.setAddresses(r.conn)
+	return connection
+}
+
+func (r *datagramReader) Close() error {
+	return r.conn.Close()
+}
@@ -0,0 +1,49 @@
+package kmh
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unit is a unit in which buffer sizes are reported.
+type Unit struct {
+	// Name is the unit's symbol, such as "KiB".
+	Name string
+	// Bytes is the number of bytes in one unit.
+	Bytes float64
+}
+
+// Units are the units in which buffer sizes may be reported: bytes and bits,
+// with SI (powers of 1000) and IEC (powers of 1024) prefixes.
+var Units = []Unit{
+	{"B", 1}, {"KB", 1e3}, {"MB", 1e6}, {"KiB", 1 << 10}, {"MiB", 1 << 20},
+	{"bit", 1.0 / 8}, {"Kbit", 1e3 / 8}, {"Mbit", 1e6 / 8}, {"Kibit", (1 << 10) / 8.0}, {"Mibit", (1 << 20) / 8.0},
+}
+
+// Bytes is the unit in which buffer sizes are reported unless another is
+// chosen.
+var Bytes = Units[0]
+
+// ParseUnit returns the unit with the given symbol.
+func ParseUnit(name string) (Unit, error) {
+	for _, unit := range Units {
+		if unit.Name == name {
+			return unit, nil
+		}
+	}
+	names := make([]string, len(Units))
+	for i, unit := range Units {
+		names[i] = unit.Name
+	}
+	return Unit{}, fmt.Errorf("unknown unit: %v (expected one of %v)", name, strings.Join(names, ", "))
+}
+
+// Convert returns bytes in the unit.
+func (u Unit) Convert(bytes float64) float64 {
+	return bytes / u.Bytes
+}
+
+// Format returns bytes, in the unit, followed by the unit's symbol.
+func (u Unit) Format(bytes float64) string {
+	return fmt.Sprintf("%.2f %v", u.Convert(bytes), u.Name)
+}
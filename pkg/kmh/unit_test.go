@@ -0,0 +1,37 @@
+package kmh
+
+import "testing"
+
+func TestUnitFormat(t *testing.T) {
+	tests := []struct {
+		unit  string
+		bytes float64
+		want  string
+	}{
+		{"B", 1536, "1536.00 B"},
+		{"KB", 1536, "1.54 KB"},
+		{"KiB", 1536, "1.50 KiB"},
+		{"MiB", 1 << 20, "1.00 MiB"},
+		{"bit", 2, "16.00 bit"},
+		{"Kbit", 1000, "8.00 Kbit"},
+		{"Kibit", 1024, "8.00 Kibit"},
+	}
+	for _, test := range tests {
+		unit, err := ParseUnit(test.unit)
+		if err != nil {
+			t.Errorf("ParseUnit(%q) = %v", test.unit, err)
+			continue
+		}
+		if got := unit.Format(test.bytes); got != test.want {
+			t.Errorf("%v: Format(%v) = %q, want %q", test.unit, test.bytes, got, test.want)
+		}
+	}
+}
+
+func TestParseUnitUnknown(t *testing.T) {
+	for _, name := range []string{"", "Kb", "kib", "bytes"} {
+		if _, err := ParseUnit(name); err == nil {
+			t.Errorf("ParseUnit(%q) = nil error, want an unknown unit", name)
+		}
+	}
+}
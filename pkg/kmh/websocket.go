@@ -0,0 +1,65 @@
+package kmh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/websocket"
+)
+
+// WebSocketSource streams the payloads of the binary frames sent over a
+// WebSocket by a periodic endpoint. URL uses the http or https scheme of the
+// opening handshake. The handshake requires HTTP/1.1.
+type WebSocketSource struct {
+	Client *http.Client
+	URL    string
+	Token  string
+}
+
+func (s WebSocketSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	key, err := websocket.NewKey()
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Key", key)
+	request.Header.Set("Sec-WebSocket-Version", websocket.Version)
+	if s.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	connection := Connection{}
+	response, err := s.Client.Do(traceAddresses(request, &connection))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		response.Body.Close()
+		return nil, fmt.Errorf("unexpected response status: %v", response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != websocket.AcceptKey(key) {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Accept", websocket.ErrProtocol)
+	}
+	stream, ok := response.Body.(io.ReadWriteCloser)
+	if !ok {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: connection cannot be upgraded", websocket.ErrProtocol)
+	}
+
+	frames := struct {
+		io.Reader
+		io.Closer
+	}{websocket.NewReader(stream), stream}
+	connection.Protocol = "websocket/" + response.Proto
+	connection.setTLS(response.TLS)
+	connection.Proxy = proxyFor(s.Client, request)
+	return describedBody{ReadCloser: frames, connection: connection}, nil
+}
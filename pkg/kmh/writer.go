@@ -0,0 +1,56 @@
+package kmh
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// KmhWriter is the send-side mirror of KmhCalculator. It wraps an io.Writer
+// and records the gaps between consecutive size-byte chunks being accepted
+// by the sink, which measures buffering on the send path.
+type KmhWriter struct {
+	context context.Context
+	sink    io.Writer
+	tracker tracker
+}
+
+// NewKmhWriter creates a KmhWriter that writes to sink. Once context is done,
+// writes fail with the context's error. NewKmhWriter panics if size is 0.
+func NewKmhWriter(context context.Context, size uint64, sink io.Writer, options ...Option) KmhWriter {
+	return KmhWriter{context: context, sink: sink, tracker: newTracker(size, options)}
+}
+
+// OnDelta registers f to be called, from the writing goroutine, each time a
+// delta is recorded. ts is the time at which the write completing the delta
+// returned.
+func (sw *KmhWriter) OnDelta(f func(d time.Duration, ts time.Time)) {
+	sw.tracker.onDelta = f
+}
+
+// Deltas returns the recorded gaps, in nanoseconds.
+func (sw *KmhWriter) Deltas() []int64 {
+	return sw.tracker.deltas()
+}
+
+// Events returns the recorded gaps along with when they occurred.
+func (sw *KmhWriter) Events() []DeltaEvent {
+	return sw.tracker.snapshot().Events
+}
+
+// Snapshot returns the progress of the measurement so far. It is safe to
+// call while another goroutine is using the KmhWriter.
+func (sw *KmhWriter) Snapshot() Snapshot {
+	return sw.tracker.snapshot()
+}
+
+func (sw *KmhWriter) Write(p []byte) (n int, err error) {
+	if err = sw.context.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err = sw.sink.Write(p)
+
+	sw.tracker.account(n)
+	return
+}
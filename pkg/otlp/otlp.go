@@ -0,0 +1,244 @@
+// Package otlp exports spans and metrics to an OpenTelemetry collector with
+// the OTLP/HTTP protocol, encoded as JSON.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exporter sends telemetry to the collector at Endpoint, such as
+// http://localhost:4318, presenting Headers with each request.
+type Exporter struct {
+	Client   *http.Client
+	Endpoint string
+	Headers  map[string]string
+	// Service names the service that produced the telemetry.
+	Service string
+}
+
+// Span is a completed operation. A span with a non-nil Err is exported with
+// an error status.
+type Span struct {
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	Events     []Event
+	Err        error
+}
+
+// Event is something that happened during a span.
+type Event struct {
+	Name       string
+	At         time.Time
+	Attributes map[string]any
+}
+
+// Gauge is a metric with a single value at a point in time.
+type Gauge struct {
+	Name        string
+	Description string
+	Unit        string
+	At          time.Time
+	Value       float64
+}
+
+// Histogram is a metric counting Values, observed from Start to At, in
+// buckets whose upper bounds are Bounds.
+type Histogram struct {
+	Name        string
+	Description string
+	Unit        string
+	Start       time.Time
+	At          time.Time
+	Bounds      []float64
+	Values      []float64
+}
+
+// ParseHeaders parses headers given as in OTEL_EXPORTER_OTLP_HEADERS:
+// comma-separated key=value pairs.
+func ParseHeaders(value string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid OTLP header: %v", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// ExportSpan exports span as the root of a new trace.
+func (e Exporter) ExportSpan(ctx context.Context, span Span) error {
+	status := map[string]any{"code": 1}
+	if span.Err != nil {
+		status = map[string]any{"code": 2, "message": span.Err.Error()}
+	}
+	events := []map[string]any{}
+	for _, event := range span.Events {
+		events = append(events, map[string]any{
+			"name":         event.Name,
+			"timeUnixNano": nanos(event.At),
+			"attributes":   attributes(event.Attributes),
+		})
+	}
+	encoded := map[string]any{
+		"traceId":           randomID(16),
+		"spanId":            randomID(8),
+		"name":              span.Name,
+		"kind":              3,
+		"startTimeUnixNano": nanos(span.Start),
+		"endTimeUnixNano":   nanos(span.End),
+		"attributes":        attributes(span.Attributes),
+		"events":            events,
+		"status":            status,
+	}
+	return e.post(ctx, "/v1/traces", map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource":   e.resource(),
+			"scopeSpans": []any{map[string]any{"scope": scope, "spans": []any{encoded}}},
+		}},
+	})
+}
+
+// ExportMetrics exports gauges and histograms.
+func (e Exporter) ExportMetrics(ctx context.Context, gauges []Gauge, histograms []Histogram) error {
+	metrics := []any{}
+	for _, gauge := range gauges {
+		metrics = append(metrics, map[string]any{
+			"name":        gauge.Name,
+			"description": gauge.Description,
+			"unit":        gauge.Unit,
+			"gauge": map[string]any{"dataPoints": []any{map[string]any{
+				"timeUnixNano": nanos(gauge.At),
+				"asDouble":     gauge.Value,
+			}}},
+		})
+	}
+	for _, histogram := range histograms {
+		counts := make([]string, len(histogram.Bounds)+1)
+		buckets := make([]uint64, len(histogram.Bounds)+1)
+		sum := 0.0
+		for _, value := range histogram.Values {
+			buckets[sort.SearchFloat64s(histogram.Bounds, value)]++
+			sum += value
+		}
+		for i, count := range buckets {
+			counts[i] = strconv.FormatUint(count, 10)
+		}
+		metrics = append(metrics, map[string]any{
+			"name":        histogram.Name,
+			"description": histogram.Description,
+			"unit":        histogram.Unit,
+			"histogram": map[string]any{
+				// Delta temporality: the counts cover only this measurement.
+				"aggregationTemporality": 1,
+				"dataPoints": []any{map[string]any{
+					"startTimeUnixNano": nanos(histogram.Start),
+					"timeUnixNano":      nanos(histogram.At),
+					"count":             strconv.Itoa(len(histogram.Values)),
+					"sum":               sum,
+					"bucketCounts":      counts,
+					"explicitBounds":    histogram.Bounds,
+				}},
+			},
+		})
+	}
+	return e.post(ctx, "/v1/metrics", map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource":     e.resource(),
+			"scopeMetrics": []any{map[string]any{"scope": scope, "metrics": metrics}},
+		}},
+	})
+}
+
+// scope identifies the instrumentation that produced the telemetry.
+var scope = map[string]any{"name": "github.com/hawkinsw/measure-buffer/v2"}
+
+func (e Exporter) resource() map[string]any {
+	return map[string]any{"attributes": attributes(map[string]any{"service.name": e.Service})}
+}
+
+func (e Exporter) post(ctx context.Context, path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range e.Headers {
+		request.Header.Set(key, value)
+	}
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected response status from collector: %v", response.Status)
+	}
+	return nil
+}
+
+// attributes encodes values as OTLP attributes, sorted by key.
+func attributes(values map[string]any) []any {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	encoded := []any{}
+	for _, key := range keys {
+		var value map[string]any
+		switch v := values[key].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, map[string]any{"key": key, "value": value})
+	}
+	return encoded
+}
+
+// nanos encodes t as nanoseconds since the epoch, as a string as the JSON
+// encoding of OTLP's 64-bit integers requires.
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// randomID returns n random bytes encoded in hexadecimal.
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
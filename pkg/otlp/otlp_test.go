@@ -0,0 +1,223 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The shapes of the OTLP/HTTP JSON requests, as given by the
+// opentelemetry-proto repository. Decoding into them disallows unknown
+// fields, so that a misspelled or misplaced field fails the tests.
+type (
+	attribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	resource struct {
+		Attributes []attribute `json:"attributes"`
+	}
+	instrumentationScope struct {
+		Name string `json:"name"`
+	}
+	numberDataPoint struct {
+		TimeUnixNano string  `json:"timeUnixNano"`
+		AsDouble     float64 `json:"asDouble"`
+	}
+	histogramDataPoint struct {
+		StartTimeUnixNano string    `json:"startTimeUnixNano"`
+		TimeUnixNano      string    `json:"timeUnixNano"`
+		Count             string    `json:"count"`
+		Sum               float64   `json:"sum"`
+		BucketCounts      []string  `json:"bucketCounts"`
+		ExplicitBounds    []float64 `json:"explicitBounds"`
+	}
+	metric struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Unit        string `json:"unit"`
+		Gauge       *struct {
+			DataPoints []numberDataPoint `json:"dataPoints"`
+		} `json:"gauge"`
+		Histogram *struct {
+			AggregationTemporality int                  `json:"aggregationTemporality"`
+			DataPoints             []histogramDataPoint `json:"dataPoints"`
+		} `json:"histogram"`
+	}
+	metricsRequest struct {
+		ResourceMetrics []struct {
+			Resource     resource `json:"resource"`
+			ScopeMetrics []struct {
+				Scope   instrumentationScope `json:"scope"`
+				Metrics []metric             `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	spanEvent struct {
+		Name         string      `json:"name"`
+		TimeUnixNano string      `json:"timeUnixNano"`
+		Attributes   []attribute `json:"attributes"`
+	}
+	span struct {
+		TraceID           string      `json:"traceId"`
+		SpanID            string      `json:"spanId"`
+		Name              string      `json:"name"`
+		Kind              int         `json:"kind"`
+		StartTimeUnixNano string      `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+		Attributes        []attribute `json:"attributes"`
+		Events            []spanEvent `json:"events"`
+		Status            struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	tracesRequest struct {
+		ResourceSpans []struct {
+			Resource   resource `json:"resource"`
+			ScopeSpans []struct {
+				Scope instrumentationScope `json:"scope"`
+				Spans []span               `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+)
+
+// collect starts a collector that decodes the request posted to it into
+// request, which must have the OTLP shape, and returns an exporter to it.
+func collect(t *testing.T, request any) Exporter {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("request headers = %v, want JSON and the exporter's headers", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(request); err != nil {
+			t.Errorf("%v: decoding %s: %v", r.URL.Path, body, err)
+		}
+	}))
+	t.Cleanup(collector.Close)
+	return Exporter{Endpoint: collector.URL + "/", Headers: map[string]string{"Authorization": "Bearer token"}, Service: "kmh"}
+}
+
+func TestExportMetrics(t *testing.T) {
+	request := metricsRequest{}
+	exporter := collect(t, &request)
+	at := time.Unix(2, 500)
+	err := exporter.ExportMetrics(context.Background(),
+		[]Gauge{{Name: "kmh.buffer", Description: "The implied buffer size.", Unit: "By", At: at, Value: 1536}},
+		[]Histogram{{Name: "kmh.delta", Unit: "s", Start: time.Unix(1, 0), At: at, Bounds: []float64{1, 2}, Values: []float64{0.5, 1.5, 1.7, 3}}})
+	if err != nil {
+		t.Fatalf("ExportMetrics() = %v", err)
+	}
+
+	if len(request.ResourceMetrics) != 1 || len(request.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("request = %+v, want one resource and one scope", request)
+	}
+	if got, want := request.ResourceMetrics[0].Resource.Attributes, []attribute{
+		{Key: "service.name", Value: map[string]any{"stringValue": "kmh"}},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resource attributes = %v, want %v", got, want)
+	}
+	metrics := request.ResourceMetrics[0].ScopeMetrics[0].Metrics
+	if len(metrics) != 2 || metrics[0].Gauge == nil || metrics[1].Histogram == nil {
+		t.Fatalf("metrics = %+v, want a gauge and a histogram", metrics)
+	}
+	if got, want := metrics[0].Gauge.DataPoints, []numberDataPoint{{TimeUnixNano: "2000000500", AsDouble: 1536}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("gauge data points = %v, want %v", got, want)
+	}
+	if metrics[0].Name != "kmh.buffer" || metrics[0].Unit != "By" || metrics[0].Description == "" {
+		t.Errorf("gauge = %+v, want its name, unit and description", metrics[0])
+	}
+	if metrics[1].Histogram.AggregationTemporality != 1 {
+		t.Errorf("histogram temporality = %v, want delta (1)", metrics[1].Histogram.AggregationTemporality)
+	}
+	want := []histogramDataPoint{{
+		StartTimeUnixNano: "1000000000", TimeUnixNano: "2000000500", Count: "4", Sum: 6.7,
+		BucketCounts: []string{"1", "2", "1"}, ExplicitBounds: []float64{1, 2},
+	}}
+	if got := metrics[1].Histogram.DataPoints; !reflect.DeepEqual(got, want) {
+		t.Errorf("histogram data points = %+v, want %+v", got, want)
+	}
+}
+
+func TestExportSpan(t *testing.T) {
+	request := tracesRequest{}
+	exporter := collect(t, &request)
+	err := exporter.ExportSpan(context.Background(), Span{
+		Name: "measure", Start: time.Unix(1, 0), End: time.Unix(3, 0),
+		Attributes: map[string]any{"size": 512, "url": "example.com", "insecure": true, "estimate": 1.5, "bytes": int64(7)},
+		Events:     []Event{{Name: "delta", At: time.Unix(2, 0), Attributes: map[string]any{"gap": time.Second}}},
+		Err:        errors.New("source closed the stream"),
+	})
+	if err != nil {
+		t.Fatalf("ExportSpan() = %v", err)
+	}
+
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("request = %+v, want a single span", request)
+	}
+	exported := request.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if len(exported.TraceID) != 32 || len(exported.SpanID) != 16 {
+		t.Errorf("IDs = %v, %v, want 16 and 8 bytes in hexadecimal", exported.TraceID, exported.SpanID)
+	}
+	if exported.StartTimeUnixNano != "1000000000" || exported.EndTimeUnixNano != "3000000000" {
+		t.Errorf("span times = %v to %v, want 1s to 3s", exported.StartTimeUnixNano, exported.EndTimeUnixNano)
+	}
+	if exported.Status.Code != 2 || exported.Status.Message != "source closed the stream" {
+		t.Errorf("status = %+v, want an error with the span's message", exported.Status)
+	}
+	attributes := []attribute{
+		{Key: "bytes", Value: map[string]any{"intValue": "7"}},
+		{Key: "estimate", Value: map[string]any{"doubleValue": 1.5}},
+		{Key: "insecure", Value: map[string]any{"boolValue": true}},
+		{Key: "size", Value: map[string]any{"intValue": "512"}},
+		{Key: "url", Value: map[string]any{"stringValue": "example.com"}},
+	}
+	if !reflect.DeepEqual(exported.Attributes, attributes) {
+		t.Errorf("attributes = %v, want %v", exported.Attributes, attributes)
+	}
+	events := []spanEvent{{Name: "delta", TimeUnixNano: "2000000000", Attributes: []attribute{
+		{Key: "gap", Value: map[string]any{"stringValue": "1s"}},
+	}}}
+	if !reflect.DeepEqual(exported.Events, events) {
+		t.Errorf("events = %v, want %v", exported.Events, events)
+	}
+}
+
+func TestExportRejected(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusBadRequest)
+	}))
+	defer collector.Close()
+	if err := (Exporter{Endpoint: collector.URL}).ExportMetrics(context.Background(), nil, nil); err == nil {
+		t.Errorf("ExportMetrics() to a collector that rejects it = nil, want an error")
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		value string
+		want  map[string]string
+		fails bool
+	}{
+		{"", map[string]string{}, false},
+		{"a=1", map[string]string{"a": "1"}, false},
+		{" a = 1 , b=x=y,", map[string]string{"a": "1", "b": "x=y"}, false},
+		{"a", nil, true},
+	}
+	for _, test := range tests {
+		got, err := ParseHeaders(test.value)
+		if (err != nil) != test.fails || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseHeaders(%q) = %v, %v, want %v (error %v)", test.value, got, err, test.want, test.fails)
+		}
+	}
+}
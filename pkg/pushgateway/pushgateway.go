@@ -0,0 +1,64 @@
+// Package pushgateway pushes gauges to a Prometheus Pushgateway, so that
+// probes that run and exit can report into a Prometheus that cannot scrape
+// them.
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Gauge is a metric whose value is pushed as a Prometheus gauge.
+type Gauge struct {
+	Name  string
+	Help  string
+	Value float64
+}
+
+// Label is a name and value that identify the group to which metrics are
+// pushed. The Pushgateway attaches a group's labels to its metrics.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Push replaces the metrics of the group identified by job and labels on the
+// Pushgateway at gateway with gauges.
+func Push(ctx context.Context, client *http.Client, gateway string, job string, labels []Label, gauges []Gauge) error {
+	path := strings.TrimSuffix(gateway, "/") + "/metrics/job/" + url.PathEscape(job)
+	for _, label := range labels {
+		// Values are base64-encoded so that they may contain slashes.
+		value := base64.RawURLEncoding.EncodeToString([]byte(label.Value))
+		if value == "" {
+			value = "="
+		}
+		path += "/" + label.Name + "@base64/" + value
+	}
+
+	body := bytes.Buffer{}
+	for _, gauge := range gauges {
+		fmt.Fprintf(&body, "# HELP %v %v\n", gauge.Name, gauge.Help)
+		fmt.Fprintf(&body, "# TYPE %v gauge\n", gauge.Name)
+		fmt.Fprintf(&body, "%v %v\n", gauge.Name, gauge.Value)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, path, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected response status from pushgateway: %v", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Reasons for which a test ends, recorded in the access log.
+const (
+	endCompleted    = "completed"
+	endClientClosed = "client_closed"
+	endShutdown     = "shutdown"
+	endWriteError   = "write_error"
+)
+
+// logTest logs one record summarizing a finished test to logger.
+func logTest(logger *slog.Logger, client string, t test, sent uint64, duration time.Duration, reason string) {
+	logger.Info("test finished",
+		"client", client, "size", t.size, "interval", t.interval, "duration", t.duration, "pacing", t.pacing,
+		"pattern", t.pattern.Kind, "bytes_sent", sent, "elapsed", duration.Round(time.Millisecond), "reason", reason)
+}
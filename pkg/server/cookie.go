@@ -0,0 +1,80 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// cookieWindow is how long cookies are issued for. A cookie is accepted
+// until the window after the one in which it was issued ends.
+const cookieWindow = 30 * time.Second
+
+// cookieInterval is the least time between the cookies sent to an address,
+// which bounds the traffic a forged request can direct at it.
+const cookieInterval = 100 * time.Millisecond
+
+// cookieJar issues and checks the cookies with which UDP clients prove that
+// they receive at the addresses from which they ask for tests.
+type cookieJar struct {
+	secret []byte
+
+	lock sync.Mutex
+	sent map[string]time.Time
+	// Addresses are forgotten once sent holds pruneAt of them.
+	pruneAt int
+}
+
+// minPruneAt is the fewest addresses the jar holds before forgetting any.
+const minPruneAt = 1024
+
+func newCookieJar() *cookieJar {
+	secret := make([]byte, sha256.Size)
+	rand.Read(secret)
+	return &cookieJar{secret: secret, sent: map[string]time.Time{}, pruneAt: minPruneAt}
+}
+
+// cookie returns the cookie of address for the given window.
+func (j *cookieJar) cookie(address net.Addr, window int64) string {
+	mac := hmac.New(sha256.New, j.secret)
+	mac.Write([]byte(address.String() + " " + strconv.FormatInt(window, 10)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// valid reports whether cookie was issued to address in this window or the
+// previous one.
+func (j *cookieJar) valid(cookie string, address net.Addr) bool {
+	window := time.Now().UnixNano() / int64(cookieWindow)
+	for _, issued := range []int64{window, window - 1} {
+		if hmac.Equal([]byte(cookie), []byte(j.cookie(address, issued))) {
+			return true
+		}
+	}
+	return false
+}
+
+// issue returns the cookie to send to address, unless one was sent to it
+// within cookieInterval.
+func (j *cookieJar) issue(address net.Addr) (string, bool) {
+	now := time.Now()
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if last, ok := j.sent[address.String()]; ok && now.Sub(last) < cookieInterval {
+		return "", false
+	}
+	if len(j.sent) >= j.pruneAt {
+		for sentTo, last := range j.sent {
+			if now.Sub(last) >= cookieInterval {
+				delete(j.sent, sentTo)
+			}
+		}
+		j.pruneAt = max(2*len(j.sent), minPruneAt)
+	}
+	j.sent[address.String()] = now
+	return j.cookie(address, now.UnixNano()/int64(cookieWindow)), true
+}
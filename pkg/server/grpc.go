@@ -0,0 +1,120 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/grpc"
+)
+
+// statusForRejection maps why a test was refused to a gRPC status code.
+var statusForRejection = map[string]int{
+	rejectedBadRequest:   grpc.StatusInvalidArgument,
+	rejectedRateLimited:  grpc.StatusResourceExhausted,
+	rejectedOverloaded:   grpc.StatusUnavailable,
+	rejectedUnauthorized: grpc.StatusUnauthenticated,
+}
+
+// failGRPC ends a call before any message is sent.
+func failGRPC(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", grpc.ContentType)
+	w.Header().Set("Grpc-Status", strconv.Itoa(status))
+	w.Header().Set("Grpc-Message", url.PathEscape(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// serveGRPC implements the Stream method of the Periodic service, sending
+// the chunks of a test as Chunk messages.
+func (s *Server) serveGRPC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		s.metrics.reject(rejectedBadRequest)
+		http.Error(w, "gRPC requires a POST over HTTP/2", http.StatusBadRequest)
+		return
+	}
+	if !s.authorized(r) {
+		s.metrics.reject(rejectedUnauthorized)
+		failGRPC(w, grpc.StatusUnauthenticated, "a valid bearer token is required")
+		return
+	}
+
+	message, err := grpc.ReadMessage(http.MaxBytesReader(w, r.Body, 4096), nil)
+	request := grpc.StreamRequest{}
+	if err == nil {
+		err = request.Unmarshal(message)
+	}
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		failGRPC(w, grpc.StatusInvalidArgument, "invalid request: "+err.Error())
+		return
+	}
+
+	t, err := s.parseTest(grpcQuery(request))
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		failGRPC(w, grpc.StatusInvalidArgument, err.Error())
+		return
+	}
+
+	release, refused := s.admit(r.RemoteAddr)
+	if refused != nil {
+		failGRPC(w, statusForRejection[refused.reason], refused.message)
+		return
+	}
+	defer release()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		failGRPC(w, grpc.StatusInternal, "streaming is not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", grpc.ContentType)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	start := time.Now()
+	sent, reason := s.stream(r.Context(), &chunkWriter{w: w}, flusher, t)
+	elapsed := time.Since(start)
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(grpc.StatusOK))
+
+	s.metrics.observeDuration(elapsed)
+	if s.config.AccessLog != nil {
+		logTest(s.config.AccessLog, "grpc://"+r.RemoteAddr, t, sent, elapsed, reason)
+	}
+}
+
+// grpcQuery returns the parameters of r as they would be given to the
+// periodic endpoint.
+func grpcQuery(r grpc.StreamRequest) url.Values {
+	query := url.Values{}
+	if r.Size != 0 {
+		query.Set("size", strconv.FormatUint(r.Size, 10))
+	}
+	if r.Interval != 0 {
+		query.Set("interval", r.Interval.String())
+	}
+	if r.Duration != 0 {
+		query.Set("duration", r.Duration.String())
+	}
+	if r.Pacing != "" {
+		query.Set("pacing", r.Pacing)
+	}
+	return query
+}
+
+// chunkWriter sends each write as a Chunk message, numbered in sequence.
+type chunkWriter struct {
+	w        io.Writer
+	sequence uint64
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.sequence++
+	if err := grpc.WriteMessage(w.w, grpc.Chunk{Sequence: w.sequence, Payload: p}.Marshal()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ipLimiter limits how often each client address may start a test using a
+// token bucket per address.
+type ipLimiter struct {
+	lock    sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// maxIdleBuckets is the number of buckets kept before full ones are swept.
+const maxIdleBuckets = 1024
+
+// newIPLimiter creates a limiter that allows each address perMinute tests
+// per minute, with bursts of up to burst tests.
+func newIPLimiter(perMinute float64, burst int) *ipLimiter {
+	return &ipLimiter{rate: perMinute / 60, burst: float64(burst), buckets: map[string]*bucket{}}
+}
+
+// allow reports whether the client at address may start a test now.
+func (l *ipLimiter) allow(address string, now time.Time) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.buckets) > maxIdleBuckets {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[host] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (l *ipLimiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.rate
+	if tokens > l.burst {
+		return l.burst
+	}
+	return tokens
+}
+
+// sweep forgets the buckets that have refilled completely.
+func (l *ipLimiter) sweep(now time.Time) {
+	for host, b := range l.buckets {
+		if l.refill(b, now) >= l.burst {
+			delete(l.buckets, host)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reasons for which a test is rejected, used as the value of the reason
+// label of kmh_tests_rejected_total.
+const (
+	rejectedBadRequest   = "bad_request"
+	rejectedRateLimited  = "rate_limited"
+	rejectedOverloaded   = "overloaded"
+	rejectedUnauthorized = "unauthorized"
+)
+
+var rejectedReasons = []string{rejectedBadRequest, rejectedRateLimited, rejectedOverloaded, rejectedUnauthorized}
+
+// durationBuckets are the upper bounds, in seconds, of the buckets of
+// kmh_test_duration_seconds.
+var durationBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+
+// metrics are the server's counters, exposed in the Prometheus text format.
+type metrics struct {
+	testsStarted atomic.Uint64
+	bytesSent    atomic.Uint64
+	rejected     map[string]*atomic.Uint64
+
+	durationLock   sync.Mutex
+	durationCounts []uint64
+	durationSum    float64
+	durationCount  uint64
+}
+
+func newMetrics() *metrics {
+	m := &metrics{rejected: map[string]*atomic.Uint64{}, durationCounts: make([]uint64, len(durationBuckets))}
+	for _, reason := range rejectedReasons {
+		m.rejected[reason] = &atomic.Uint64{}
+	}
+	return m
+}
+
+func (m *metrics) reject(reason string) {
+	m.rejected[reason].Add(1)
+}
+
+func (m *metrics) observeDuration(duration time.Duration) {
+	m.durationLock.Lock()
+	defer m.durationLock.Unlock()
+	seconds := duration.Seconds()
+	for i, bound := range durationBuckets {
+		if seconds <= bound {
+			m.durationCounts[i]++
+		}
+	}
+	m.durationSum += seconds
+	m.durationCount++
+}
+
+// write writes the metrics to w. active is the number of tests in progress.
+func (m *metrics) write(w io.Writer, active int64) {
+	fmt.Fprintf(w, "# HELP kmh_tests_active Tests in progress.\n")
+	fmt.Fprintf(w, "# TYPE kmh_tests_active gauge\n")
+	fmt.Fprintf(w, "kmh_tests_active %v\n", active)
+
+	fmt.Fprintf(w, "# HELP kmh_tests_started_total Tests started.\n")
+	fmt.Fprintf(w, "# TYPE kmh_tests_started_total counter\n")
+	fmt.Fprintf(w, "kmh_tests_started_total %v\n", m.testsStarted.Load())
+
+	fmt.Fprintf(w, "# HELP kmh_bytes_sent_total Bytes sent to clients.\n")
+	fmt.Fprintf(w, "# TYPE kmh_bytes_sent_total counter\n")
+	fmt.Fprintf(w, "kmh_bytes_sent_total %v\n", m.bytesSent.Load())
+
+	fmt.Fprintf(w, "# HELP kmh_tests_rejected_total Tests refused, by reason.\n")
+	fmt.Fprintf(w, "# TYPE kmh_tests_rejected_total counter\n")
+	for _, reason := range rejectedReasons {
+		fmt.Fprintf(w, "kmh_tests_rejected_total{reason=%q} %v\n", reason, m.rejected[reason].Load())
+	}
+
+	m.durationLock.Lock()
+	defer m.durationLock.Unlock()
+	fmt.Fprintf(w, "# HELP kmh_test_duration_seconds Duration of completed tests.\n")
+	fmt.Fprintf(w, "# TYPE kmh_test_duration_seconds histogram\n")
+	for i, bound := range durationBuckets {
+		fmt.Fprintf(w, "kmh_test_duration_seconds_bucket{le=\"%v\"} %v\n", bound, m.durationCounts[i])
+	}
+	fmt.Fprintf(w, "kmh_test_duration_seconds_bucket{le=\"+Inf\"} %v\n", m.durationCount)
+	fmt.Fprintf(w, "kmh_test_duration_seconds_sum %v\n", m.durationSum)
+	fmt.Fprintf(w, "kmh_test_duration_seconds_count %v\n", m.durationCount)
+}
+
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.write(w, s.active.Load())
+}
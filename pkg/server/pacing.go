@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Pacing is how a server spaces the chunks of a test.
+type Pacing string
+
+const (
+	// PacingFixed sends one chunk every interval.
+	PacingFixed Pacing = "fixed"
+	// PacingJittered sends one chunk every interval, each displaced from
+	// its nominal time by up to a fraction of the interval.
+	PacingJittered Pacing = "jittered"
+	// PacingBursty sends several chunks back to back, keeping the same
+	// average rate as PacingFixed.
+	PacingBursty Pacing = "bursty"
+)
+
+// ParsePacing returns the Pacing named name.
+func ParsePacing(name string) (Pacing, error) {
+	switch pacing := Pacing(name); pacing {
+	case PacingFixed, PacingJittered, PacingBursty:
+		return pacing, nil
+	}
+	return "", fmt.Errorf("unknown pacing: %v", name)
+}
+
+// pacer schedules the chunks of a test.
+type pacer struct {
+	pacing   Pacing
+	pattern  Pattern
+	size     uint64
+	interval time.Duration
+	jitter   float64
+	burst    int
+	start    time.Time
+	nominal  time.Time
+}
+
+func newPacer(t test, jitter float64, burst int, start time.Time) *pacer {
+	if burst < 1 {
+		burst = 1
+	}
+	return &pacer{
+		pacing: t.pacing, pattern: t.pattern, size: t.size, interval: t.interval,
+		jitter: jitter, burst: burst, start: start, nominal: start,
+	}
+}
+
+// next returns when the next chunks are due, how many to send then and how
+// large each is. Times are computed from a nominal schedule so that delays
+// in sending do not accumulate.
+func (p *pacer) next() (time.Time, int, uint64) {
+	size, interval := p.size, p.interval
+	multiplier := p.pattern.multiplier(p.nominal.Sub(p.start))
+	if p.pattern.Vary == VarySize {
+		size = uint64(float64(size) * multiplier)
+	} else {
+		interval = time.Duration(float64(interval) * multiplier)
+	}
+
+	switch p.pacing {
+	case PacingJittered:
+		p.nominal = p.nominal.Add(interval)
+		offset := time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(interval))
+		return p.nominal.Add(offset), 1, size
+	case PacingBursty:
+		p.nominal = p.nominal.Add(interval * time.Duration(p.burst))
+		return p.nominal, p.burst, size
+	}
+	p.nominal = p.nominal.Add(interval)
+	return p.nominal, 1, size
+}
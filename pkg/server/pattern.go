@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Pattern is how a server varies the chunk size or the interval over the
+// life of a test. The varied quantity is multiplied by a factor that moves
+// between 1 and the pattern's Factor.
+type Pattern struct {
+	// Kind is the shape of the variation.
+	Kind PatternKind
+	// Vary is the quantity being varied.
+	Vary PatternTarget
+	// Factor is the multiplier at the pattern's peak.
+	Factor float64
+	// Period is the length of the ramp, the time before the step or the
+	// length of each half of the square wave.
+	Period time.Duration
+}
+
+// PatternKind is the shape of a Pattern.
+type PatternKind string
+
+const (
+	// PatternNone keeps the size and the interval constant.
+	PatternNone PatternKind = ""
+	// PatternRamp moves linearly from 1 to Factor over Period, then holds.
+	PatternRamp PatternKind = "ramp"
+	// PatternStep holds 1 for Period, then holds Factor.
+	PatternStep PatternKind = "step"
+	// PatternSquare alternates between 1 and Factor every Period.
+	PatternSquare PatternKind = "square"
+)
+
+// PatternTarget is the quantity varied by a Pattern.
+type PatternTarget string
+
+const (
+	VarySize     PatternTarget = "size"
+	VaryInterval PatternTarget = "interval"
+)
+
+// parsePattern reads a pattern from the pattern, vary, factor and period
+// query parameters.
+func parsePattern(query url.Values) (Pattern, error) {
+	p := Pattern{Vary: VarySize, Factor: 2, Period: 10 * time.Second}
+
+	switch kind := PatternKind(query.Get("pattern")); kind {
+	case PatternNone, PatternRamp, PatternStep, PatternSquare:
+		p.Kind = kind
+	default:
+		return Pattern{}, fmt.Errorf("unknown pattern: %v", kind)
+	}
+
+	if value := query.Get("vary"); value != "" {
+		switch vary := PatternTarget(value); vary {
+		case VarySize, VaryInterval:
+			p.Vary = vary
+		default:
+			return Pattern{}, fmt.Errorf("invalid vary: %v", value)
+		}
+	}
+
+	if value := query.Get("factor"); value != "" {
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil || factor <= 0 || math.IsInf(factor, 0) {
+			return Pattern{}, fmt.Errorf("invalid factor: %v", value)
+		}
+		p.Factor = factor
+	}
+
+	if value := query.Get("period"); value != "" {
+		period, err := time.ParseDuration(value)
+		if err != nil || period <= 0 {
+			return Pattern{}, fmt.Errorf("invalid period: %v", value)
+		}
+		p.Period = period
+	}
+	return p, nil
+}
+
+// multiplier returns the factor applied to the varied quantity elapsed into
+// the test.
+func (p Pattern) multiplier(elapsed time.Duration) float64 {
+	switch p.Kind {
+	case PatternRamp:
+		if elapsed >= p.Period {
+			return p.Factor
+		}
+		return 1 + (p.Factor-1)*float64(elapsed)/float64(p.Period)
+	case PatternStep:
+		if elapsed >= p.Period {
+			return p.Factor
+		}
+	case PatternSquare:
+		if (elapsed/p.Period)%2 == 1 {
+			return p.Factor
+		}
+	}
+	return 1
+}
+
+// bounds returns the smallest and largest multipliers the pattern uses.
+func (p Pattern) bounds() (float64, float64) {
+	if p.Kind == PatternNone {
+		return 1, 1
+	}
+	return math.Min(1, p.Factor), math.Max(1, p.Factor)
+}
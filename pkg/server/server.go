@@ -0,0 +1,468 @@
+// Package server implements the periodic endpoint measured by package kmh.
+//
+// The endpoint streams a chunk of data at a fixed interval until the test's
+// duration elapses or the client disconnects. Clients may choose the size,
+// interval, duration and pacing of a test with query parameters of the same
+// names, within the limits set by the server. A pattern, chosen with the
+// pattern, vary, factor and period parameters, may vary the size or interval
+// over the life of the test.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/grpc"
+)
+
+// Config describes a periodic server.
+type Config struct {
+	// Address is the address on which to listen, such as ":443".
+	Address string
+	// UnixSocket, if not empty, is the path of a Unix domain socket on which
+	// to listen instead of Address.
+	UnixSocket string
+	// CertFile and KeyFile hold the server's TLS certificate and key. When
+	// both are empty, a self-signed certificate is generated at startup.
+	CertFile string
+	KeyFile  string
+	// Hostnames are the names for which a generated certificate is valid.
+	// If empty, DefaultHostnames is used.
+	Hostnames []string
+	// HTTPVersion is the newest HTTP version offered to clients: "1.1" or
+	// "2". If empty, "2" is used. HTTP/2 clients may still fall back to
+	// HTTP/1.1.
+	HTTPVersion string
+	// Size is the chunk size used when a request does not specify one.
+	Size uint64
+	// Interval is the time between chunks used when a request does not
+	// specify one.
+	Interval time.Duration
+	// Pacing is how chunks are spaced when a request does not specify a
+	// pacing. If empty, PacingFixed is used.
+	Pacing Pacing
+	// Jitter is the largest displacement, as a fraction of the interval, of
+	// a chunk sent with PacingJittered.
+	Jitter float64
+	// BurstChunks is the number of chunks sent together with PacingBursty.
+	BurstChunks int
+	// MaxSize is the largest chunk size a request may ask for.
+	MaxSize uint64
+	// MinInterval and MaxInterval bound the interval a request may ask for.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// MaxDuration is the longest a test may last. A request that does not
+	// specify a duration lasts MaxDuration. Zero means unlimited.
+	MaxDuration time.Duration
+	// MaxConcurrent is the largest number of tests that may run at once.
+	// Requests beyond it are refused with 503 Service Unavailable. Zero
+	// means unlimited.
+	MaxConcurrent int
+	// TestsPerMinute is how many tests each client address may start per
+	// minute, with bursts of up to Burst tests. Requests beyond it are
+	// refused with 429 Too Many Requests. Zero means unlimited.
+	TestsPerMinute float64
+	Burst          int
+	// Metrics exposes Prometheus metrics at /metrics.
+	Metrics bool
+	// Tokens, if not empty, are the bearer tokens accepted by the periodic
+	// endpoint. Requests without one of them are refused with 401
+	// Unauthorized.
+	Tokens []string
+	// AccessLog, if not nil, receives one record per finished test.
+	AccessLog *slog.Logger
+	// UDPAddress is the address on which ListenAndServeUDP listens.
+	UDPAddress string
+	// TCPAddress is the address on which ListenAndServeTCP listens.
+	TCPAddress string
+}
+
+// test is the shape of a single periodic stream.
+type test struct {
+	size     uint64
+	interval time.Duration
+	duration time.Duration
+	pacing   Pacing
+	pattern  Pattern
+}
+
+// Server serves the periodic endpoint over HTTPS.
+type Server struct {
+	config     Config
+	httpServer *http.Server
+	active     atomic.Int64
+	draining   atomic.Bool
+	limiter    *ipLimiter
+	metrics    *metrics
+
+	// Tests over UDP and raw TCP are tracked by the server itself.
+	listenersLock sync.Mutex
+	listeners     []io.Closer
+	udpClients    sync.Map
+	cookies       *cookieJar
+	streams       sync.WaitGroup
+	closing       context.Context
+	closeStreams  context.CancelFunc
+}
+
+// New creates a server described by config.
+func New(config Config) *Server {
+	s := &Server{config: config, metrics: newMetrics(), cookies: newCookieJar()}
+	s.closing, s.closeStreams = context.WithCancel(context.Background())
+	s.httpServer = &http.Server{Addr: config.Address, Handler: s.Handler(), TLSConfig: &tls.Config{}}
+	if config.TestsPerMinute > 0 {
+		burst := config.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		s.limiter = newIPLimiter(config.TestsPerMinute, burst)
+	}
+	return s
+}
+
+// Handler returns a handler that serves the periodic endpoint at /periodic,
+// the same stream over a WebSocket at /websocket and as the gRPC Periodic
+// service and, if enabled, metrics at /metrics.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/periodic", s.servePeriodic)
+	mux.HandleFunc("/websocket", s.serveWebSocket)
+	mux.HandleFunc(grpc.StreamMethod, s.serveGRPC)
+	if s.config.Metrics {
+		mux.HandleFunc("/metrics", s.serveMetrics)
+	}
+	return mux
+}
+
+// ListenAndServe listens on the configured address and serves the periodic
+// endpoint until an error occurs or the server is shut down, in which case
+// it returns http.ErrServerClosed.
+func (s *Server) ListenAndServe() error {
+	httpServer := s.httpServer
+
+	switch s.config.HTTPVersion {
+	case "", "2":
+	case "1.1":
+		// A non-nil, empty TLSNextProto keeps the server from offering h2.
+		httpServer.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		httpServer.TLSConfig.NextProtos = []string{"http/1.1"}
+	default:
+		return fmt.Errorf("unknown HTTP version: %v", s.config.HTTPVersion)
+	}
+
+	if s.config.CertFile == "" && s.config.KeyFile == "" {
+		hostnames := s.config.Hostnames
+		if len(hostnames) == 0 {
+			hostnames = DefaultHostnames()
+		}
+		certificate, err := GenerateCertificate(hostnames)
+		if err != nil {
+			return fmt.Errorf("generating certificate: %w", err)
+		}
+		httpServer.TLSConfig.Certificates = []tls.Certificate{certificate}
+	}
+	if s.config.UnixSocket != "" {
+		listener, err := net.Listen("unix", s.config.UnixSocket)
+		if err != nil {
+			return err
+		}
+		return httpServer.ServeTLS(listener, s.config.CertFile, s.config.KeyFile)
+	}
+	return httpServer.ListenAndServeTLS(s.config.CertFile, s.config.KeyFile)
+}
+
+// Shutdown stops the server from accepting new tests and waits for the tests
+// in progress, over every transport, to finish. If ctx is done first, the
+// remaining tests are closed and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	// No stream is started once the server is draining, so none is added
+	// while the streams are waited for. The TCP listeners are closed now, as
+	// their connections outlive them; the UDP socket carries its streams, so
+	// it stays open until they end.
+	s.listenersLock.Lock()
+	s.draining.Store(true)
+	for _, listener := range s.listeners {
+		if _, ok := listener.(net.Listener); ok {
+			listener.Close()
+		}
+	}
+	s.listenersLock.Unlock()
+
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		s.httpServer.Close()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		s.streams.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	s.closeStreams()
+
+	s.listenersLock.Lock()
+	defer s.listenersLock.Unlock()
+	for _, listener := range s.listeners {
+		if _, ok := listener.(net.Listener); !ok {
+			listener.Close()
+		}
+	}
+	return err
+}
+
+// startStream counts a UDP or TCP stream that is about to start, unless the
+// server is draining, in which case it reports false.
+func (s *Server) startStream() bool {
+	s.listenersLock.Lock()
+	defer s.listenersLock.Unlock()
+	if s.draining.Load() {
+		return false
+	}
+	s.streams.Add(1)
+	return true
+}
+
+// track registers listener to be closed by Shutdown, or closes it if the
+// server is already draining.
+func (s *Server) track(listener io.Closer) {
+	s.listenersLock.Lock()
+	defer s.listenersLock.Unlock()
+	if s.draining.Load() {
+		listener.Close()
+		return
+	}
+	s.listeners = append(s.listeners, listener)
+}
+
+// parseTest reads the size, interval, duration, pacing and pattern of a test
+// from query, enforcing the server's limits.
+func (s *Server) parseTest(query url.Values) (test, error) {
+	t := test{size: s.config.Size, interval: s.config.Interval, duration: s.config.MaxDuration, pacing: s.config.Pacing}
+	if t.pacing == "" {
+		t.pacing = PacingFixed
+	}
+
+	if value := query.Get("size"); value != "" {
+		size, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || size == 0 {
+			return test{}, fmt.Errorf("invalid size: %v", value)
+		}
+		t.size = size
+	}
+
+	if value := query.Get("interval"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return test{}, fmt.Errorf("invalid interval: %v", value)
+		}
+		t.interval = interval
+	}
+
+	if value := query.Get("duration"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return test{}, fmt.Errorf("invalid duration: %v", value)
+		}
+		if s.config.MaxDuration != 0 && duration > s.config.MaxDuration {
+			return test{}, fmt.Errorf("duration %v exceeds the maximum of %v", duration, s.config.MaxDuration)
+		}
+		t.duration = duration
+	}
+
+	if value := query.Get("pacing"); value != "" {
+		pacing, err := ParsePacing(value)
+		if err != nil {
+			return test{}, err
+		}
+		t.pacing = pacing
+	}
+
+	pattern, err := parsePattern(query)
+	if err != nil {
+		return test{}, err
+	}
+	t.pattern = pattern
+
+	// The limits apply to every size and interval the pattern reaches.
+	smallest, largest := pattern.bounds()
+	sizeFactor, minIntervalFactor, maxIntervalFactor := float64(1), float64(1), float64(1)
+	if pattern.Vary == VarySize {
+		sizeFactor = largest
+	} else {
+		minIntervalFactor, maxIntervalFactor = smallest, largest
+	}
+	if largestSize := float64(t.size) * sizeFactor; s.config.MaxSize != 0 && largestSize > float64(s.config.MaxSize) {
+		return test{}, fmt.Errorf("size %v exceeds the maximum of %v", uint64(largestSize), s.config.MaxSize)
+	}
+	shortest := time.Duration(float64(t.interval) * minIntervalFactor)
+	longest := time.Duration(float64(t.interval) * maxIntervalFactor)
+	if shortest < s.config.MinInterval || (s.config.MaxInterval != 0 && longest > s.config.MaxInterval) {
+		return test{}, fmt.Errorf("interval %v is outside of [%v, %v]", t.interval, s.config.MinInterval, s.config.MaxInterval)
+	}
+	return t, nil
+}
+
+// validToken reports whether presented is one of the configured tokens, or
+// whether no tokens are configured.
+func (s *Server) validToken(presented string) bool {
+	if len(s.config.Tokens) == 0 {
+		return true
+	}
+	valid := false
+	for _, token := range s.config.Tokens {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// authorized reports whether r carries one of the configured tokens.
+func (s *Server) authorized(r *http.Request) bool {
+	presented, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.validToken(presented)
+}
+
+// rejection is why a test was refused.
+type rejection struct {
+	// reason labels the rejection in the metrics.
+	reason     string
+	status     int
+	retryAfter string
+	message    string
+}
+
+// admit decides whether the client at address may start a test now. If so,
+// it returns a function to call once the test is over. Otherwise, it
+// records and returns the rejection.
+func (s *Server) admit(address string) (func(), *rejection) {
+	if s.limiter != nil && !s.limiter.allow(address, time.Now()) {
+		s.metrics.reject(rejectedRateLimited)
+		return nil, &rejection{rejectedRateLimited, http.StatusTooManyRequests, "60", "too many tests from this address"}
+	}
+	active := s.active.Add(1)
+	release := func() { s.active.Add(-1) }
+	if s.draining.Load() {
+		release()
+		s.metrics.reject(rejectedOverloaded)
+		return nil, &rejection{rejectedOverloaded, http.StatusServiceUnavailable, "", "the server is shutting down"}
+	}
+	if s.config.MaxConcurrent > 0 && active > int64(s.config.MaxConcurrent) {
+		release()
+		s.metrics.reject(rejectedOverloaded)
+		return nil, &rejection{rejectedOverloaded, http.StatusServiceUnavailable, "10", "too many tests in progress"}
+	}
+	s.metrics.testsStarted.Add(1)
+	return release, nil
+}
+
+func (s *Server) servePeriodic(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		s.metrics.reject(rejectedUnauthorized)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kmh"`)
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+		return
+	}
+
+	t, err := s.parseTest(r.URL.Query())
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	release, refused := s.admit(r.RemoteAddr)
+	if refused != nil {
+		if refused.retryAfter != "" {
+			w.Header().Set("Retry-After", refused.retryAfter)
+		}
+		http.Error(w, refused.message, refused.status)
+		return
+	}
+	defer release()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	start := time.Now()
+	sent, reason := s.stream(r.Context(), w, flusher, t)
+	elapsed := time.Since(start)
+
+	s.metrics.observeDuration(elapsed)
+	if s.config.AccessLog != nil {
+		logTest(s.config.AccessLog, r.RemoteAddr, t, sent, elapsed, reason)
+	}
+}
+
+// stream sends the chunks of t to w until the test's duration elapses or ctx
+// is done. It returns the number of bytes sent and why the test ended.
+func (s *Server) stream(ctx context.Context, w io.Writer, flusher http.Flusher, t test) (uint64, string) {
+	testCtx := ctx
+	if t.duration != 0 {
+		var cancel context.CancelFunc
+		testCtx, cancel = context.WithTimeout(ctx, t.duration)
+		defer cancel()
+	}
+
+	_, largest := t.pattern.bounds()
+	if t.pattern.Vary != VarySize {
+		largest = 1
+	}
+	buffer := make([]byte, uint64(float64(t.size)*largest))
+	pacer := newPacer(t, s.config.Jitter, s.config.BurstChunks, time.Now())
+	due, chunks, size := pacer.next()
+	timer := time.NewTimer(time.Until(due))
+	defer timer.Stop()
+
+	sent := uint64(0)
+	for {
+		select {
+		case <-testCtx.Done():
+			switch {
+			case ctx.Err() == nil:
+				return sent, endCompleted
+			case s.draining.Load():
+				return sent, endShutdown
+			}
+			return sent, endClientClosed
+		case <-timer.C:
+		}
+
+		for i := 0; i < chunks; i++ {
+			written, err := w.Write(buffer[:size])
+			sent += uint64(written)
+			s.metrics.bytesSent.Add(uint64(written))
+			if err != nil {
+				return sent, endWriteError
+			}
+		}
+		flusher.Flush()
+
+		due, chunks, size = pacer.next()
+		timer.Reset(time.Until(due))
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tcpRequestTimeout is how long a raw TCP client has to send its request.
+const tcpRequestTimeout = 10 * time.Second
+
+// ListenAndServeTCP listens for raw TCP connections on the configured TCP
+// address. A client sends a single line holding the parameters of its test,
+// URL-encoded as for the periodic endpoint and including an optional token,
+// after which the server sends the chunks of the test with no framing. A
+// refused test is answered with a line beginning "error: ". It returns once
+// the server is shut down.
+func (s *Server) ListenAndServeTCP() error {
+	listener, err := net.Listen("tcp", s.config.TCPAddress)
+	if err != nil {
+		return err
+	}
+	s.track(listener)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.draining.Load() {
+				return nil
+			}
+			return err
+		}
+		if !s.startStream() {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.streams.Done()
+			defer conn.Close()
+			s.serveTCP(conn)
+		}()
+	}
+}
+
+func refuseTCP(conn net.Conn, message string) {
+	fmt.Fprintf(conn, "error: %v\n", message)
+}
+
+func (s *Server) serveTCP(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(tcpRequestTimeout))
+	request, err := bufio.NewReaderSize(conn, 4096).ReadString('\n')
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		refuseTCP(conn, "a request line is required")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	query, err := url.ParseQuery(strings.TrimSpace(request))
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		refuseTCP(conn, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if !s.validToken(query.Get("token")) {
+		s.metrics.reject(rejectedUnauthorized)
+		refuseTCP(conn, "a valid token is required")
+		return
+	}
+	t, err := s.parseTest(query)
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		refuseTCP(conn, err.Error())
+		return
+	}
+
+	address := conn.RemoteAddr().String()
+	release, refused := s.admit(address)
+	if refused != nil {
+		refuseTCP(conn, refused.message)
+		return
+	}
+	defer release()
+
+	start := time.Now()
+	sent, reason := s.stream(s.closing, conn, noFlusher{}, t)
+	elapsed := time.Since(start)
+
+	s.metrics.observeDuration(elapsed)
+	if s.config.AccessLog != nil {
+		logTest(s.config.AccessLog, "tcp://"+address, t, sent, elapsed, reason)
+	}
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/datagram"
+)
+
+// ListenAndServeUDP listens for request datagrams on the configured UDP
+// address and sends a stream of data datagrams to each client that requests
+// a test and echoes its cookie, as described by package datagram. Datagram
+// tests accept the same parameters as the periodic endpoint, but must have a
+// finite duration. It returns once the server is shut down.
+func (s *Server) ListenAndServeUDP() error {
+	conn, err := net.ListenPacket("udp", s.config.UDPAddress)
+	if err != nil {
+		return err
+	}
+	s.track(conn)
+
+	request := make([]byte, datagram.MaxSize)
+	for {
+		n, address, err := conn.ReadFrom(request)
+		if err != nil {
+			if s.draining.Load() {
+				return nil
+			}
+			return err
+		}
+
+		if n < datagram.MinRequestSize {
+			continue
+		}
+		query, err := url.ParseQuery(string(request[:n]))
+		if err != nil {
+			s.metrics.reject(rejectedBadRequest)
+			refuseDatagrams(conn, address, fmt.Sprintf("invalid request: %v", err), n)
+			continue
+		}
+		if !s.cookies.valid(query.Get("cookie"), address) {
+			if cookie, ok := s.cookies.issue(address); ok {
+				reply := make([]byte, datagram.HeaderSize+len(cookie))
+				datagram.Header{Sequence: datagram.CookieSequence, Sent: time.Now()}.Put(reply)
+				copy(reply[datagram.HeaderSize:], cookie)
+				conn.WriteTo(reply, address)
+			}
+			continue
+		}
+
+		// Requests repeated while a test runs are ignored.
+		if _, running := s.udpClients.LoadOrStore(address.String(), true); running {
+			continue
+		}
+		if !s.startStream() {
+			s.udpClients.Delete(address.String())
+			continue
+		}
+		go func() {
+			defer s.streams.Done()
+			defer s.udpClients.Delete(address.String())
+			s.serveDatagrams(conn, address, query)
+		}()
+	}
+}
+
+// refuseDatagrams tells the client at address why its test was refused, in a
+// datagram of at most limit bytes.
+func refuseDatagrams(conn net.PacketConn, address net.Addr, message string, limit int) {
+	if len(message) > limit-datagram.HeaderSize {
+		message = message[:max(limit-datagram.HeaderSize, 0)]
+	}
+	refusal := make([]byte, datagram.HeaderSize+len(message))
+	datagram.Header{Sequence: 0, Sent: time.Now()}.Put(refusal)
+	copy(refusal[datagram.HeaderSize:], message)
+	conn.WriteTo(refusal, address)
+}
+
+// serveDatagrams sends the test requested by query to address, which has
+// echoed its cookie.
+func (s *Server) serveDatagrams(conn net.PacketConn, address net.Addr, query url.Values) {
+	if !s.validToken(query.Get("token")) {
+		s.metrics.reject(rejectedUnauthorized)
+		refuseDatagrams(conn, address, "a valid token is required", datagram.MaxSize)
+		return
+	}
+
+	t, err := s.parseTest(query)
+	if err == nil {
+		err = checkDatagramTest(t)
+	}
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		refuseDatagrams(conn, address, err.Error(), datagram.MaxSize)
+		return
+	}
+
+	release, refused := s.admit(address.String())
+	if refused != nil {
+		refuseDatagrams(conn, address, refused.message, datagram.MaxSize)
+		return
+	}
+	defer release()
+
+	start := time.Now()
+	sent, reason := s.stream(s.closing, &datagramWriter{conn: conn, address: address}, noFlusher{}, t)
+	elapsed := time.Since(start)
+	if reason == endWriteError && s.draining.Load() {
+		reason = endShutdown
+	}
+
+	s.metrics.observeDuration(elapsed)
+	if s.config.AccessLog != nil {
+		logTest(s.config.AccessLog, "udp://"+address.String(), t, sent, elapsed, reason)
+	}
+}
+
+// checkDatagramTest reports whether t can be sent as datagrams.
+func checkDatagramTest(t test) error {
+	if t.duration == 0 {
+		return fmt.Errorf("datagram tests require a duration")
+	}
+	smallest, largest := float64(1), float64(1)
+	if t.pattern.Vary == VarySize {
+		smallest, largest = t.pattern.bounds()
+	}
+	if float64(t.size)*smallest < datagram.HeaderSize || float64(t.size)*largest > datagram.MaxSize {
+		return fmt.Errorf("datagram size must be between %v and %v", datagram.HeaderSize, datagram.MaxSize)
+	}
+	return nil
+}
+
+// datagramWriter sends each write as a datagram, numbered in sequence.
+type datagramWriter struct {
+	conn     net.PacketConn
+	address  net.Addr
+	sequence uint64
+}
+
+func (w *datagramWriter) Write(p []byte) (int, error) {
+	w.sequence++
+	datagram.Header{Sequence: w.sequence, Sent: time.Now()}.Put(p)
+	return w.conn.WriteTo(p, w.address)
+}
+
+type noFlusher struct{}
+
+func (noFlusher) Flush() {}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hawkinsw/measure-buffer/v2/pkg/websocket"
+)
+
+// serveWebSocket streams the chunks of a test as binary WebSocket frames. It
+// accepts the same parameters as the periodic endpoint.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || r.Header.Get("Sec-WebSocket-Key") == "" {
+		s.metrics.reject(rejectedBadRequest)
+		http.Error(w, "a WebSocket handshake is required", http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != websocket.Version {
+		s.metrics.reject(rejectedBadRequest)
+		w.Header().Set("Sec-WebSocket-Version", websocket.Version)
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	if !s.authorized(r) {
+		s.metrics.reject(rejectedUnauthorized)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kmh"`)
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+		return
+	}
+
+	t, err := s.parseTest(r.URL.Query())
+	if err != nil {
+		s.metrics.reject(rejectedBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket requires HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	release, refused := s.admit(r.RemoteAddr)
+	if refused != nil {
+		if refused.retryAfter != "" {
+			w.Header().Set("Retry-After", refused.retryAfter)
+		}
+		http.Error(w, refused.message, refused.status)
+		return
+	}
+	defer release()
+
+	// Once hijacked, the connection is no longer the HTTP server's, so it is
+	// tracked, and closed by Shutdown, like those of the other transports.
+	// It is counted before the hijack, while the HTTP server's Shutdown still
+	// waits for this request.
+	s.streams.Add(1)
+	defer s.streams.Done()
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stop := context.AfterFunc(s.closing, cancel)
+	defer stop()
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	buffered.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	buffered.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	buffered.WriteString("Sec-WebSocket-Accept: " + websocket.AcceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	if err := buffered.Flush(); err != nil {
+		return
+	}
+
+	start := time.Now()
+	frames := &frameWriter{buffered: buffered.Writer}
+	sent, reason := s.stream(ctx, frames, frames, t)
+	elapsed := time.Since(start)
+	websocket.WriteFrame(buffered, websocket.OpClose, nil)
+	buffered.Flush()
+
+	s.metrics.observeDuration(elapsed)
+	if s.config.AccessLog != nil {
+		logTest(s.config.AccessLog, "ws://"+r.RemoteAddr, t, sent, elapsed, reason)
+	}
+}
+
+// frameWriter sends each write as a binary frame.
+type frameWriter struct {
+	buffered *bufio.Writer
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	if err := websocket.WriteFrame(w.buffered, websocket.OpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *frameWriter) Flush() {
+	w.buffered.Flush()
+}
@@ -0,0 +1,210 @@
+// Package socks5 implements the client side of the SOCKS5 protocol (RFC
+// 1928), including username and password authentication (RFC 1929), so that
+// connections can be made through a SOCKS proxy.
+package socks5
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	version          = 5
+	authVersion      = 1
+	methodNone       = 0
+	methodPassword   = 2
+	methodRefused    = 0xff
+	commandConnect   = 1
+	addressIPv4      = 1
+	addressDomain    = 3
+	addressIPv6      = 4
+	replySucceeded   = 0
+	handshakeTimeout = 10 * time.Second
+)
+
+// ErrProtocol is returned when the proxy violates the protocol.
+var ErrProtocol = errors.New("SOCKS5 protocol error")
+
+// replies are the meanings of the proxy's reply codes.
+var replies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// Dialer connects to addresses through the SOCKS5 proxy at Address. If
+// Username is not empty, it authenticates with Username and Password. If
+// Forward is not nil, it makes the connection to the proxy.
+type Dialer struct {
+	Address  string
+	Username string
+	Password string
+	Forward  func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DialContext connects to address through the proxy. Only TCP networks are
+// supported. Host names are resolved by the proxy.
+func (d Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("SOCKS5 does not support network %v", network)
+	}
+
+	forward := d.Forward
+	if forward == nil {
+		forward = (&net.Dialer{}).DialContext
+	}
+	conn, err := forward(ctx, "tcp", d.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(handshakeTimeout)
+	}
+	conn.SetDeadline(deadline)
+	if err := d.handshake(conn, address); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to %v through SOCKS5 proxy %v: %w", address, d.Address, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d Dialer) handshake(conn net.Conn, address string) error {
+	methods := []byte{methodNone}
+	if d.Username != "" {
+		methods = []byte{methodPassword}
+	}
+	greeting := append([]byte{version, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+	chosen := [2]byte{}
+	if _, err := io.ReadFull(conn, chosen[:]); err != nil {
+		return err
+	}
+	if chosen[0] != version {
+		return fmt.Errorf("%w: unexpected version %v", ErrProtocol, chosen[0])
+	}
+	switch chosen[1] {
+	case methodNone:
+	case methodPassword:
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	case methodRefused:
+		return errors.New("the proxy accepts none of the offered authentication methods")
+	default:
+		return fmt.Errorf("%w: unexpected authentication method %v", ErrProtocol, chosen[1])
+	}
+
+	request, err := connectRequest(address)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	return readReply(conn)
+}
+
+func (d Dialer) authenticate(conn net.Conn) error {
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return errors.New("SOCKS5 username and password must be at most 255 bytes")
+	}
+	request := []byte{authVersion, byte(len(d.Username))}
+	request = append(request, d.Username...)
+	request = append(request, byte(len(d.Password)))
+	request = append(request, d.Password...)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+	status := [2]byte{}
+	if _, err := io.ReadFull(conn, status[:]); err != nil {
+		return err
+	}
+	if status[1] != 0 {
+		return errors.New("the proxy rejected the username and password")
+	}
+	return nil
+}
+
+// connectRequest returns the request to connect to address.
+func connectRequest(address string) ([]byte, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port: %v", portString)
+	}
+
+	request := []byte{version, commandConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name is too long: %v", host)
+		}
+		request = append(request, addressDomain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, addressIPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, addressIPv6)
+		request = append(request, ip...)
+	}
+	return binary.BigEndian.AppendUint16(request, uint16(port)), nil
+}
+
+// readReply reads the proxy's reply to a connect request, returning an error
+// if it failed.
+func readReply(conn net.Conn) error {
+	reply := [4]byte{}
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != version {
+		return fmt.Errorf("%w: unexpected version %v", ErrProtocol, reply[0])
+	}
+	if reply[1] != replySucceeded {
+		message, ok := replies[reply[1]]
+		if !ok {
+			message = fmt.Sprintf("reply %v", reply[1])
+		}
+		return errors.New(message)
+	}
+
+	// The address the proxy bound is of no use here, but must be consumed.
+	length := 0
+	switch reply[3] {
+	case addressIPv4:
+		length = net.IPv4len
+	case addressIPv6:
+		length = net.IPv6len
+	case addressDomain:
+		size := [1]byte{}
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return err
+		}
+		length = int(size[0])
+	default:
+		return fmt.Errorf("%w: unexpected address type %v", ErrProtocol, reply[3])
+	}
+	_, err := io.ReadFull(conn, make([]byte, length+2))
+	return err
+}
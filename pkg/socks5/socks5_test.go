@@ -0,0 +1,143 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestConnectRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    []byte
+		fails   bool
+	}{
+		{"IPv4", "192.0.2.1:443", []byte{5, 1, 0, 1, 192, 0, 2, 1, 0x01, 0xbb}, false},
+		{"IPv6", "[2001:db8::1]:80", []byte{5, 1, 0, 4, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 80}, false},
+		{"domain", "example.com:8080", append(append([]byte{5, 1, 0, 3, 11}, "example.com"...), 0x1f, 0x90), false},
+		{"over-long host", strings.Repeat("a", 256) + ":80", nil, true},
+		{"no port", "example.com", nil, true},
+		{"invalid port", "example.com:65536", nil, true},
+	}
+	for _, test := range tests {
+		got, err := connectRequest(test.address)
+		if (err != nil) != test.fails || !bytes.Equal(got, test.want) {
+			t.Errorf("%v: connectRequest(%q) = %v, %v, want %v (error %v)", test.name, test.address, got, err, test.want, test.fails)
+		}
+	}
+}
+
+// serve writes reply to the client end of a pipe, followed by a marker byte
+// that must be left unread by whatever reads the reply, and returns the
+// client end.
+func serve(t *testing.T, reply []byte) net.Conn {
+	client, proxy := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		defer proxy.Close()
+		proxy.Write(append(reply, '!'))
+	}()
+	return client
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []byte
+		want  string
+	}{
+		{"IPv4", []byte{5, 0, 0, 1, 192, 0, 2, 1, 0x01, 0xbb}, ""},
+		{"IPv6", append(append([]byte{5, 0, 0, 4}, make([]byte, 16)...), 0, 80), ""},
+		{"domain", append(append([]byte{5, 0, 0, 3, 11}, "example.com"...), 0, 80), ""},
+		{"refused", []byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}, "connection refused"},
+		{"unknown reply", []byte{5, 42, 0, 1, 0, 0, 0, 0, 0, 0}, "reply 42"},
+		{"version", []byte{4, 0, 0, 1, 0, 0, 0, 0, 0, 0}, ErrProtocol.Error()},
+		{"address type", []byte{5, 0, 0, 9, 0, 0}, ErrProtocol.Error()},
+	}
+	for _, test := range tests {
+		conn := serve(t, test.reply)
+		err := readReply(conn)
+		switch {
+		case test.want == "" && err != nil:
+			t.Errorf("%v: readReply() = %v", test.name, err)
+		case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+			t.Errorf("%v: readReply() = %v, want an error containing %q", test.name, err, test.want)
+		case test.want == "":
+			marker := make([]byte, 1)
+			if _, err := io.ReadFull(conn, marker); err != nil || marker[0] != '!' {
+				t.Errorf("%v: readReply() left %q unread, want the reply consumed exactly", test.name, marker)
+			}
+		}
+	}
+}
+
+func TestReadReplyTruncated(t *testing.T) {
+	if err := readReply(serve(t, []byte{5, 0, 0, 1, 192})); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("readReply() of a truncated reply = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// proxy answers a client that authenticates as user with password and asks
+// to connect to address.
+func proxy(t *testing.T, conn net.Conn, user, password, address string) {
+	defer conn.Close()
+	read := func(n int) []byte {
+		b := make([]byte, n)
+		if _, err := io.ReadFull(conn, b); err != nil {
+			t.Errorf("proxy: %v", err)
+		}
+		return b
+	}
+	if greeting := read(3); !bytes.Equal(greeting, []byte{5, 1, methodPassword}) {
+		t.Errorf("proxy: greeting = %v, want the password method offered", greeting)
+	}
+	conn.Write([]byte{5, methodPassword})
+	credentials := append(append(append([]byte{1, byte(len(user))}, user...), byte(len(password))), password...)
+	if got := read(len(credentials)); !bytes.Equal(got, credentials) {
+		t.Errorf("proxy: credentials = %v, want %v", got, credentials)
+	}
+	conn.Write([]byte{1, 0})
+	request, _ := connectRequest(address)
+	if got := read(len(request)); !bytes.Equal(got, request) {
+		t.Errorf("proxy: request = %v, want %v", got, request)
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	conn.Write([]byte("hello"))
+}
+
+func TestDialContext(t *testing.T) {
+	client, server := net.Pipe()
+	go proxy(t, server, "user", "secret", "example.com:443")
+	dialer := Dialer{
+		Address: "proxy:1080", Username: "user", Password: "secret",
+		Forward: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if address != "proxy:1080" {
+				t.Errorf("Forward() to %v, want proxy:1080", address)
+			}
+			return client, nil
+		},
+	}
+	conn, err := dialer.DialContext(context.Background(), "tcp", "example.com:443")
+	if err != nil {
+		t.Fatalf("DialContext() = %v", err)
+	}
+	defer conn.Close()
+	if greeting, err := io.ReadAll(conn); err != nil || string(greeting) != "hello" {
+		t.Errorf("read %q, %v through the proxy, want %q", greeting, err, "hello")
+	}
+
+	if _, err := dialer.DialContext(context.Background(), "udp", "example.com:443"); err == nil {
+		t.Errorf("DialContext() over UDP = nil, want an error")
+	}
+	refused := Dialer{Address: "proxy:1080", Forward: func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("unreachable")
+	}}
+	if _, err := refused.DialContext(context.Background(), "tcp", "example.com:443"); err == nil {
+		t.Errorf("DialContext() through an unreachable proxy = nil, want an error")
+	}
+}
@@ -0,0 +1,338 @@
+// Package stats provides descriptive statistics over slices of numbers.
+//
+// Functions that return a float64 return NaN when given no values.
+package stats
+
+import (
+	"math"
+	"sort"
+
+	"golang.org/x/exp/constraints"
+)
+
+// Number is the set of types the statistics operate on.
+type Number interface {
+	constraints.Integer | constraints.Float
+}
+
+// Sum returns the sum of values.
+func Sum[T Number](values []T) float64 {
+	total := float64(0)
+	for _, v := range values {
+		total += float64(v)
+	}
+	return total
+}
+
+// Mean returns the arithmetic mean of values.
+func Mean[T Number](values []T) float64 {
+	return Sum(values) / float64(len(values))
+}
+
+// HarmonicMean returns the harmonic mean of values, the reciprocal of the
+// mean of their reciprocals. Values must be positive.
+func HarmonicMean[T Number](values []T) float64 {
+	reciprocals := float64(0)
+	for _, v := range values {
+		reciprocals += 1 / float64(v)
+	}
+	return float64(len(values)) / reciprocals
+}
+
+// Min returns the smallest of values, or the zero value if there are none.
+func Min[T Number](values []T) T {
+	var minimum T
+	for i, v := range values {
+		if i == 0 || v < minimum {
+			minimum = v
+		}
+	}
+	return minimum
+}
+
+// Max returns the largest of values, or the zero value if there are none.
+func Max[T Number](values []T) T {
+	var maximum T
+	for i, v := range values {
+		if i == 0 || v > maximum {
+			maximum = v
+		}
+	}
+	return maximum
+}
+
+// Sorted returns a sorted copy of values.
+func Sorted[T Number](values []T) []T {
+	sorted := append([]T(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// Median returns the middle value of values.
+func Median[T Number](values []T) float64 {
+	return Percentile(values, 50)
+}
+
+// Percentile returns the pth percentile of values, for p between 0 and 100,
+// interpolating linearly between the closest ranks.
+func Percentile[T Number](values []T, p float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := Sorted(values)
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower < 0 {
+		return float64(sorted[0])
+	}
+	if upper >= len(sorted) {
+		return float64(sorted[len(sorted)-1])
+	}
+	fraction := rank - float64(lower)
+	return float64(sorted[lower]) + fraction*(float64(sorted[upper])-float64(sorted[lower]))
+}
+
+// Variance returns the sample variance of values.
+func Variance[T Number](values []T) float64 {
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	mean := Mean(values)
+	total := float64(0)
+	for _, v := range values {
+		total += (float64(v) - mean) * (float64(v) - mean)
+	}
+	return total / float64(len(values)-1)
+}
+
+// StdDev returns the sample standard deviation of values.
+func StdDev[T Number](values []T) float64 {
+	return math.Sqrt(Variance(values))
+}
+
+// Skewness returns the skewness of values: their third central moment
+// divided by the cube of their population standard deviation. It is positive
+// when the values have a long upper tail.
+func Skewness[T Number](values []T) float64 {
+	m2, m3, _ := moments(values)
+	return m3 / math.Pow(m2, 1.5)
+}
+
+// Kurtosis returns the excess kurtosis of values: their fourth central
+// moment divided by the square of their population variance, less the 3 of
+// the normal distribution. It is positive when the values are heavy-tailed.
+func Kurtosis[T Number](values []T) float64 {
+	m2, _, m4 := moments(values)
+	return m4/(m2*m2) - 3
+}
+
+// moments returns the second, third and fourth central moments of values.
+func moments[T Number](values []T) (float64, float64, float64) {
+	mean := Mean(values)
+	var m2, m3, m4 float64
+	for _, v := range values {
+		d := float64(v) - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	n := float64(len(values))
+	return m2 / n, m3 / n, m4 / n
+}
+
+// CV returns the coefficient of variation of values, their sample standard
+// deviation divided by their mean.
+func CV[T Number](values []T) float64 {
+	return StdDev(values) / Mean(values)
+}
+
+// Jitter returns the mean absolute difference between successive values.
+func Jitter[T Number](values []T) float64 {
+	if len(values) < 2 {
+		return math.NaN()
+	}
+	total := float64(0)
+	for i := 1; i < len(values); i++ {
+		total += math.Abs(float64(values[i]) - float64(values[i-1]))
+	}
+	return total / float64(len(values)-1)
+}
+
+// Bucket counts the values from Low up to, but not including, High. The last
+// of a histogram's buckets also counts values equal to its High.
+type Bucket struct {
+	Low   float64
+	High  float64
+	Count int
+}
+
+// LinearHistogram counts values in n buckets of equal width spanning them.
+func LinearHistogram[T Number](values []T, n int) []Bucket {
+	if len(values) == 0 || n < 1 {
+		return nil
+	}
+	low, high := float64(Min(values)), float64(Max(values))
+	return histogram(values, n, func(i int) float64 {
+		return low + (high-low)*float64(i)/float64(n)
+	})
+}
+
+// LogHistogram counts values in n buckets whose widths grow geometrically,
+// so that values spanning orders of magnitude are resolved. Values must be
+// positive; if any is not, LogHistogram returns nil.
+func LogHistogram[T Number](values []T, n int) []Bucket {
+	if len(values) == 0 || n < 1 || Min(values) <= 0 {
+		return nil
+	}
+	low, high := float64(Min(values)), float64(Max(values))
+	return histogram(values, n, func(i int) float64 {
+		return low * math.Pow(high/low, float64(i)/float64(n))
+	})
+}
+
+// histogram counts values in n buckets whose ith edge is edge(i).
+func histogram[T Number](values []T, n int, edge func(i int) float64) []Bucket {
+	if edge(0) == edge(n) {
+		// The values are all the same.
+		return []Bucket{{Low: edge(0), High: edge(n), Count: len(values)}}
+	}
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i] = Bucket{Low: edge(i), High: edge(i + 1)}
+	}
+	for _, v := range values {
+		i := sort.Search(n, func(i int) bool { return float64(v) < buckets[i].High })
+		if i == n {
+			i = n - 1
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// MAD returns the median absolute deviation of values from their median.
+func MAD[T Number](values []T) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	median := Median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(float64(v) - median)
+	}
+	return Median(deviations)
+}
+
+// TrimmedMean returns the arithmetic mean of values after dropping the
+// smallest and largest percent of them, for percent between 0 and 50.
+func TrimmedMean[T Number](values []T, percent float64) float64 {
+	sorted := Sorted(values)
+	drop := int(float64(len(sorted)) * percent / 100)
+	if 2*drop >= len(sorted) {
+		return Median(values)
+	}
+	return Mean(sorted[drop : len(sorted)-drop])
+}
+
+// tQuantiles975 are the 97.5th percentiles of Student's t distribution with
+// 1 to 30 degrees of freedom.
+var tQuantiles975 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// MeanInterval95 returns the bounds of the t-based 95% confidence interval
+// for the mean of the population from which values were sampled. It returns
+// NaNs when given fewer than two values.
+func MeanInterval95[T Number](values []T) (float64, float64) {
+	if len(values) < 2 {
+		return math.NaN(), math.NaN()
+	}
+	t := 1.96
+	if freedom := len(values) - 1; freedom <= len(tQuantiles975) {
+		t = tQuantiles975[freedom-1]
+	}
+	mean := Mean(values)
+	margin := t * StdDev(values) / math.Sqrt(float64(len(values)))
+	return mean - margin, mean + margin
+}
+
+// ChangePoints splits values at the points where their mean shifts, found by
+// binary segmentation: each segment is split where the cumulative sum of its
+// deviations from its mean peaks, if that peak, scaled by the noise of the
+// values and the square root of the segment's length, exceeds threshold.
+// Segments shorter than minimum are not split. The noise is estimated from
+// the differences between successive values, so that shifts in the mean do
+// not inflate it. ChangePoints returns the indexes at which new segments
+// begin, in order.
+func ChangePoints[T Number](values []T, threshold float64, minimum int) []int {
+	if len(values) < 2 {
+		return nil
+	}
+	differences := make([]float64, len(values)-1)
+	for i := range differences {
+		differences[i] = float64(values[i+1]) - float64(values[i])
+	}
+	noise := 1.4826 * MAD(differences) / math.Sqrt2
+	if noise == 0 {
+		noise = StdDev(values)
+	}
+	if noise == 0 || math.IsNaN(noise) {
+		return nil
+	}
+	minimum = max(minimum, 1)
+
+	var points []int
+	var split func(start, end int)
+	split = func(start, end int) {
+		n := end - start
+		if n < 2*minimum {
+			return
+		}
+		mean := Mean(values[start:end])
+		sum, peak, at := 0.0, 0.0, 0
+		for i := start; i < end-1; i++ {
+			sum += float64(values[i]) - mean
+			if i+1-start >= minimum && end-i-1 >= minimum && math.Abs(sum) > peak {
+				peak, at = math.Abs(sum), i+1
+			}
+		}
+		if at == 0 || peak/(noise*math.Sqrt(float64(n))) <= threshold {
+			return
+		}
+		split(start, at)
+		points = append(points, at)
+		split(at, end)
+	}
+	split(0, len(values))
+	return points
+}
+
+// LinearFit fits y = slope*x + intercept to the pairs of x and y by least
+// squares and returns the coefficient of determination, R², of the fit. It
+// returns NaNs when given fewer than two pairs or when the x are all equal.
+func LinearFit[T, U Number](x []T, y []U) (float64, float64, float64) {
+	n := min(len(x), len(y))
+	if n < 2 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	meanX, meanY := Mean(x[:n]), Mean(y[:n])
+	var sxx, sxy, syy float64
+	for i := 0; i < n; i++ {
+		dx, dy := float64(x[i])-meanX, float64(y[i])-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	slope := sxy / sxx
+	r2 := 1.0
+	if syy != 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return slope, meanY - slope*meanX, r2
+}
@@ -0,0 +1,223 @@
+package stats
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// near reports whether got and want are within a small tolerance of each
+// other, or are both NaN.
+func near(got, want float64) bool {
+	if math.IsNaN(want) {
+		return math.IsNaN(got)
+	}
+	return math.Abs(got-want) <= 1e-9*max(1, math.Abs(want))
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		p      float64
+		want   float64
+	}{
+		{"empty", nil, 50, math.NaN()},
+		{"single", []int64{7}, 90, 7},
+		{"median of even count", []int64{1, 2, 3, 4}, 50, 2.5},
+		{"unsorted", []int64{4, 1, 3, 2}, 50, 2.5},
+		{"interpolated", []int64{1, 2, 3, 4}, 25, 1.75},
+		{"minimum", []int64{1, 2, 3, 4}, 0, 1},
+		{"maximum", []int64{1, 2, 3, 4}, 100, 4},
+	}
+	for _, test := range tests {
+		if got := Percentile(test.values, test.p); !near(got, test.want) {
+			t.Errorf("%v: Percentile(%v, %v) = %v, want %v", test.name, test.values, test.p, got, test.want)
+		}
+	}
+}
+
+func TestVariance(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"empty", nil, math.NaN()},
+		{"single", []float64{3}, math.NaN()},
+		{"identical", []float64{3, 3}, 0},
+		{"sample", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 32.0 / 7},
+	}
+	for _, test := range tests {
+		if got := Variance(test.values); !near(got, test.want) {
+			t.Errorf("%v: Variance(%v) = %v, want %v", test.name, test.values, got, test.want)
+		}
+	}
+}
+
+func TestLinearHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		n      int
+		want   []Bucket
+	}{
+		{"empty", nil, 3, nil},
+		{"no buckets", []int64{1, 2}, 0, nil},
+		{"identical", []int64{5, 5, 5}, 3, []Bucket{{Low: 5, High: 5, Count: 3}}},
+		{"two buckets", []int64{1, 2, 3, 4}, 2, []Bucket{{Low: 1, High: 2.5, Count: 2}, {Low: 2.5, High: 4, Count: 2}}},
+		{"maximum in last bucket", []int64{0, 10}, 4, []Bucket{
+			{Low: 0, High: 2.5, Count: 1}, {Low: 2.5, High: 5}, {Low: 5, High: 7.5}, {Low: 7.5, High: 10, Count: 1},
+		}},
+	}
+	for _, test := range tests {
+		if got := LinearHistogram(test.values, test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: LinearHistogram(%v, %v) = %v, want %v", test.name, test.values, test.n, got, test.want)
+		}
+	}
+}
+
+func TestLogHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		n      int
+		want   []Bucket
+	}{
+		{"empty", nil, 3, nil},
+		{"no buckets", []int64{1, 2}, 0, nil},
+		{"not positive", []int64{0, 10}, 2, nil},
+		{"identical", []int64{2, 2}, 4, []Bucket{{Low: 2, High: 2, Count: 2}}},
+		{"decades", []int64{1, 10, 100}, 2, []Bucket{{Low: 1, High: 10, Count: 1}, {Low: 10, High: 100, Count: 2}}},
+	}
+	for _, test := range tests {
+		if got := LogHistogram(test.values, test.n); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: LogHistogram(%v, %v) = %v, want %v", test.name, test.values, test.n, got, test.want)
+		}
+	}
+}
+
+func TestMAD(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int64
+		want   float64
+	}{
+		{"empty", nil, math.NaN()},
+		{"identical", []int64{4, 4, 4}, 0},
+		{"skewed", []int64{1, 1, 2, 2, 4, 6, 9}, 1},
+	}
+	for _, test := range tests {
+		if got := MAD(test.values); !near(got, test.want) {
+			t.Errorf("%v: MAD(%v) = %v, want %v", test.name, test.values, got, test.want)
+		}
+	}
+}
+
+func TestTrimmedMean(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []int64
+		percent float64
+		want    float64
+	}{
+		{"empty", nil, 10, math.NaN()},
+		{"untrimmed", []int64{1, 2, 3, 4, 100}, 0, 22},
+		{"outliers dropped", []int64{100, 1, 3, 2, 4}, 20, 3},
+		{"everything trimmed", []int64{1, 2, 3, 4}, 50, 2.5},
+	}
+	for _, test := range tests {
+		if got := TrimmedMean(test.values, test.percent); !near(got, test.want) {
+			t.Errorf("%v: TrimmedMean(%v, %v) = %v, want %v", test.name, test.values, test.percent, got, test.want)
+		}
+	}
+}
+
+func TestMeanInterval95(t *testing.T) {
+	margin := 4.303 / math.Sqrt(3)
+	tests := []struct {
+		name      string
+		values    []float64
+		low, high float64
+	}{
+		{"empty", nil, math.NaN(), math.NaN()},
+		{"single", []float64{1}, math.NaN(), math.NaN()},
+		{"identical", []float64{5, 5, 5}, 5, 5},
+		{"three", []float64{1, 2, 3}, 2 - margin, 2 + margin},
+	}
+	for _, test := range tests {
+		if low, high := MeanInterval95(test.values); !near(low, test.low) || !near(high, test.high) {
+			t.Errorf("%v: MeanInterval95(%v) = %v, %v, want %v, %v", test.name, test.values, low, high, test.low, test.high)
+		}
+	}
+}
+
+func TestChangePoints(t *testing.T) {
+	step := make([]int64, 20)
+	alternating := make([]int64, 20)
+	for i := range step {
+		if i >= 10 {
+			step[i] = 10
+		}
+		alternating[i] = int64(1 + i%2)
+	}
+	tests := []struct {
+		name   string
+		values []int64
+		want   []int
+	}{
+		{"empty", nil, nil},
+		{"single", []int64{1}, nil},
+		{"constant", []int64{3, 3, 3, 3, 3, 3, 3, 3, 3, 3}, nil},
+		{"no shift", alternating, nil},
+		{"step", step, []int{10}},
+	}
+	for _, test := range tests {
+		if got := ChangePoints(test.values, 1.36, 5); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: ChangePoints(%v) = %v, want %v", test.name, test.values, got, test.want)
+		}
+	}
+}
+
+func TestLinearFit(t *testing.T) {
+	tests := []struct {
+		name                 string
+		x, y                 []float64
+		slope, intercept, r2 float64
+	}{
+		{"empty", nil, nil, math.NaN(), math.NaN(), math.NaN()},
+		{"single", []float64{1}, []float64{1}, math.NaN(), math.NaN(), math.NaN()},
+		{"equal x", []float64{2, 2, 2}, []float64{1, 2, 3}, math.NaN(), math.NaN(), math.NaN()},
+		{"exact", []float64{0, 1, 2, 3}, []float64{1, 3, 5, 7}, 2, 1, 1},
+		{"flat", []float64{0, 1, 2}, []float64{2, 2, 2}, 0, 2, 1},
+		{"noisy", []float64{1, 2, 3}, []float64{1, 3, 2}, 0.5, 1, 0.25},
+	}
+	for _, test := range tests {
+		slope, intercept, r2 := LinearFit(test.x, test.y)
+		if !near(slope, test.slope) || !near(intercept, test.intercept) || !near(r2, test.r2) {
+			t.Errorf("%v: LinearFit(%v, %v) = %v, %v, %v, want %v, %v, %v", test.name, test.x, test.y,
+				slope, intercept, r2, test.slope, test.intercept, test.r2)
+		}
+	}
+}
+
+func TestNoValues(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+	}{
+		{"Mean", Mean([]int64(nil))},
+		{"HarmonicMean", HarmonicMean([]int64(nil))},
+		{"Median", Median([]int64(nil))},
+		{"StdDev", StdDev([]int64(nil))},
+		{"Skewness", Skewness([]int64(nil))},
+		{"Kurtosis", Kurtosis([]int64(nil))},
+		{"CV", CV([]int64(nil))},
+		{"Jitter", Jitter([]int64(nil))},
+	}
+	for _, test := range tests {
+		if !math.IsNaN(test.got) {
+			t.Errorf("%v of no values = %v, want NaN", test.name, test.got)
+		}
+	}
+}
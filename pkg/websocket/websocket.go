@@ -0,0 +1,152 @@
+// Package websocket implements the small part of the WebSocket protocol
+// (RFC 6455) needed to carry a periodic stream: the opening handshake,
+// unmasked binary frames from the server and a reader that turns the frames
+// back into a stream of bytes.
+package websocket
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Opcodes of the frames used by the periodic stream.
+const (
+	OpContinuation byte = 0x0
+	OpBinary       byte = 0x2
+	OpClose        byte = 0x8
+	OpPing         byte = 0x9
+	OpPong         byte = 0xa
+)
+
+// Version is the protocol version sent in Sec-WebSocket-Version.
+const Version = "13"
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrProtocol is returned when a peer violates the protocol.
+var ErrProtocol = errors.New("websocket protocol error")
+
+// NewKey returns a random Sec-WebSocket-Key.
+func NewKey() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept that answers key.
+func AcceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// WriteFrame writes a single, final, unmasked frame, as sent by a server.
+func WriteFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch length := len(payload); {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// Reader reads the payloads of data frames as a single stream of bytes.
+// Control frames are skipped; a close frame ends the stream with io.EOF.
+type Reader struct {
+	source    io.Reader
+	remaining uint64
+	mask      []byte
+	offset    uint64
+}
+
+// NewReader returns a Reader of the frames in source.
+func NewReader(source io.Reader) *Reader {
+	return &Reader{source: source}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for r.remaining == 0 {
+		opcode, err := r.nextFrame()
+		if err != nil {
+			return 0, err
+		}
+		switch opcode {
+		case OpBinary, OpContinuation, 0x1:
+		case OpClose:
+			return 0, io.EOF
+		default:
+			if _, err := io.CopyN(io.Discard, r.source, int64(r.remaining)); err != nil {
+				return 0, err
+			}
+			r.remaining = 0
+		}
+	}
+
+	if uint64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.source.Read(p)
+	if r.mask != nil {
+		for i := 0; i < n; i++ {
+			p[i] ^= r.mask[(r.offset+uint64(i))%4]
+		}
+	}
+	r.remaining -= uint64(n)
+	r.offset += uint64(n)
+	return n, err
+}
+
+// nextFrame reads the header of the next frame and returns its opcode.
+func (r *Reader) nextFrame() (byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r.source, header); err != nil {
+		return 0, err
+	}
+	opcode := header[0] & 0x0f
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(r.source, extended); err != nil {
+			return 0, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(r.source, extended); err != nil {
+			return 0, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if opcode >= OpClose && length > 125 {
+		return 0, fmt.Errorf("%w: control frame of %v bytes", ErrProtocol, length)
+	}
+
+	r.mask = nil
+	if header[1]&0x80 != 0 {
+		r.mask = make([]byte, 4)
+		if _, err := io.ReadFull(r.source, r.mask); err != nil {
+			return 0, err
+		}
+	}
+	r.remaining = length
+	r.offset = 0
+	return opcode, nil
+}
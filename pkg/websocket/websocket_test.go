@@ -0,0 +1,115 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"testing"
+)
+
+// payload returns n bytes that differ from their neighbours.
+func payload(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i % 251)
+	}
+	return b
+}
+
+// masked returns a final frame with opcode carrying payload masked with key,
+// as sent by a client.
+func masked(opcode byte, payload []byte, key [4]byte) []byte {
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, key[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^key[i%4])
+	}
+	return frame
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		length int
+		header int
+	}{
+		{1, 2},
+		{125, 2},
+		{126, 4},
+		{0xffff, 4},
+		{0x10000, 10},
+	}
+	for _, test := range tests {
+		sent := payload(test.length)
+		frames := bytes.Buffer{}
+		if err := WriteFrame(&frames, OpBinary, sent); err != nil {
+			t.Fatalf("WriteFrame() = %v", err)
+		}
+		if frames.Len() != test.header+test.length {
+			t.Errorf("WriteFrame() of %v bytes wrote %v bytes, want a header of %v", test.length, frames.Len(), test.header)
+		}
+		received, err := io.ReadAll(NewReader(&frames))
+		if err != nil {
+			t.Errorf("reading %v bytes: %v", test.length, err)
+		}
+		if !bytes.Equal(received, sent) {
+			t.Errorf("read %v bytes back, want the %v written", len(received), test.length)
+		}
+	}
+}
+
+func TestReaderFrames(t *testing.T) {
+	frames := bytes.Buffer{}
+	WriteFrame(&frames, OpBinary, []byte("abc"))
+	WriteFrame(&frames, OpPing, []byte("ping"))
+	WriteFrame(&frames, OpBinary, nil)
+	frames.Write(masked(OpContinuation, []byte("defghij"), [4]byte{1, 2, 3, 4}))
+	WriteFrame(&frames, OpClose, []byte{0x03, 0xe8})
+	WriteFrame(&frames, OpBinary, []byte("after close"))
+
+	// Small reads check that the mask is applied across them.
+	reader := NewReader(&frames)
+	received := []byte{}
+	chunk := make([]byte, 3)
+	for {
+		n, err := reader.Read(chunk)
+		received = append(received, chunk[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read() = %v", err)
+		}
+	}
+	if string(received) != "abcdefghij" {
+		t.Errorf("read %q, want %q", received, "abcdefghij")
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	oversized := bytes.Buffer{}
+	WriteFrame(&oversized, OpPing, payload(126))
+	if _, err := io.ReadAll(NewReader(&oversized)); !errors.Is(err, ErrProtocol) {
+		t.Errorf("reading an oversized control frame = %v, want %v", err, ErrProtocol)
+	}
+
+	header := bytes.Buffer{}
+	WriteFrame(&header, OpBinary, payload(0x10000))
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(header.Bytes()[:5]))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("reading a truncated header = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestAcceptKey(t *testing.T) {
+	// The example of RFC 6455, section 1.3.
+	if got, want := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Errorf("AcceptKey() = %v, want %v", got, want)
+	}
+	key, err := NewKey()
+	if err != nil {
+		t.Fatalf("NewKey() = %v", err)
+	}
+	if nonce, err := base64.StdEncoding.DecodeString(key); err != nil || len(nonce) != 16 {
+		t.Errorf("NewKey() = %q, want 16 bytes encoded in base64", key)
+	}
+}